@@ -0,0 +1,304 @@
+// Package api exposes the state of the parsed supply data over HTTP.
+package api
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+	"github.com/ziogaschr/supply-tracer-parser/validate"
+)
+
+// Option configures the optional endpoints of the API
+type Option func(*options)
+
+type options struct {
+	validator *validate.Validator
+
+	reconcile   bool
+	allowAnchor bool
+
+	auth        *Auth
+	rateLimit   *RateLimit
+	corsOrigins []string
+}
+
+// WithValidator exposes the validation report of v at /validation
+func WithValidator(v *validate.Validator) Option {
+	return func(o *options) {
+		o.validator = v
+	}
+}
+
+// WithReconcile exposes the reconciliation against a known total supply at /reconcile.
+// When allowAnchor is set, authenticated requests can anchor the tracked total supply to the known one,
+// which requires WithAuth, with /reconcile not public.
+func WithReconcile(allowAnchor bool) Option {
+	return func(o *options) {
+		o.reconcile = true
+		o.allowAnchor = allowAnchor
+	}
+}
+
+// WithAuth requires the requests to be authenticated by a bearer token or an HMAC signature,
+// except the ones of the public paths
+func WithAuth(auth Auth) Option {
+	return func(o *options) {
+		o.auth = &auth
+	}
+}
+
+// WithRateLimit limits the rate of the requests of each client.
+// The requests over the limit are answered with 429 Too Many Requests.
+func WithRateLimit(limit RateLimit) Option {
+	return func(o *options) {
+		o.rateLimit = &limit
+	}
+}
+
+// WithCORS allows cross-origin requests from browsers of the origins, "*" allowing any origin
+func WithCORS(origins []string) Option {
+	return func(o *options) {
+		o.corsOrigins = origins
+	}
+}
+
+// Handler returns the HTTP handler exposing the latest state of the parsed supply data.
+// It can be mounted on an existing server.
+func Handler(s *tracker.State, opts ...Option) http.Handler {
+	o := newOptions(opts)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		unit, err := requestUnit(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if unit != nil {
+			writeJSON(w, formatTotalSupply(s.Snapshot(), *unit))
+			return
+		}
+
+		schema, err := requestSchema(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if schema == tracker.SchemaV2 {
+			writeJSON(w, tracker.SignedTotalSupply(s.Snapshot()))
+			return
+		}
+
+		s.RLock()
+		defer s.RUnlock()
+
+		writeJSON(w, s)
+	})
+
+	mux.HandleFunc("/eras", func(w http.ResponseWriter, r *http.Request) {
+		unit, err := requestUnit(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if unit != nil {
+			writeJSON(w, formatEraTotals(s.EraTotals(), *unit))
+			return
+		}
+
+		writeJSON(w, s.EraTotals())
+	})
+
+	mux.HandleFunc("/series", func(w http.ResponseWriter, r *http.Request) {
+		handleSeries(w, r, s)
+	})
+
+	mux.HandleFunc("/rates", func(w http.ResponseWriter, r *http.Request) {
+		unit, err := requestUnit(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if unit != nil {
+			writeJSON(w, formatRates(s.Rates(), *unit))
+			return
+		}
+
+		writeJSON(w, s.Rates())
+	})
+
+	if o.validator != nil {
+		mux.HandleFunc("/validation", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, o.validator.Report())
+		})
+	}
+
+	if o.reconcile {
+		mux.HandleFunc("/reconcile", func(w http.ResponseWriter, r *http.Request) {
+			handleReconcile(w, r, s, o.anchorAllowed())
+		})
+	}
+
+	// Metrics
+	mux.HandleFunc("/debug/vars", handleMetrics)
+
+	// Preflight requests are answered before authentication, and the clients
+	// over the rate limit are rejected before checking their credentials
+	var handler http.Handler = mux
+	if o.auth != nil {
+		handler = withAuth(handler, *o.auth)
+	}
+	if o.rateLimit != nil && o.rateLimit.Rate > 0 {
+		handler = withRateLimit(handler, newRateLimiter(*o.rateLimit))
+	}
+	if len(o.corsOrigins) > 0 {
+		handler = withCORS(handler, o.corsOrigins)
+	}
+
+	return handler
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// anchorAllowed returns whether the requests can anchor the tracked total supply,
+// as they are authenticated to do so
+func (o *options) anchorAllowed() bool {
+	if !o.allowAnchor || o.auth == nil {
+		return false
+	}
+	for _, path := range o.auth.Public {
+		if path == "/reconcile" {
+			return false
+		}
+	}
+	return true
+}
+
+// Start starts the API server on the specified port, on all interfaces.
+// It exposes the latest state of the parsed supply data
+func Start(port int, s *tracker.State, opts ...Option) error {
+	srv, err := NewServer(ServerConfig{Addr: fmt.Sprintf(":%d", port)}, s, opts...)
+	if err != nil {
+		return err
+	}
+	if err := srv.ListenAndServe(); err != nil {
+		return fmt.Errorf("failed to start server: %v", err)
+	}
+
+	return nil
+}
+
+// hiddenVars are the expvar variables left out of the metrics.
+// The command line would expose the secrets of the flags.
+var hiddenVars = map[string]bool{
+	"cmdline":  true,
+	"memstats": true,
+}
+
+// handleMetrics serves the published expvar variables, except the hidden ones
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	fmt.Fprintf(w, "{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if hiddenVars[kv.Key] {
+			return
+		}
+		if !first {
+			fmt.Fprintf(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprintf(w, "\n}\n")
+}
+
+// handleSeries serves the buckets of the `interval` query parameter, hour, day or week,
+// optionally within the `from` and `to` unix times
+func handleSeries(w http.ResponseWriter, r *http.Request, s *tracker.State) {
+	query := r.URL.Query()
+
+	interval := tracker.Day
+	if name := query.Get("interval"); name != "" {
+		var err error
+		if interval, err = tracker.ParseInterval(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	from, to := uint64(0), uint64(math.MaxUint64)
+	for _, bound := range []struct {
+		name  string
+		value *uint64
+	}{{"from", &from}, {"to", &to}} {
+		if param := query.Get(bound.name); param != "" {
+			value, err := strconv.ParseUint(param, 10, 64)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid %s: %v", bound.name, err), http.StatusBadRequest)
+				return
+			}
+			*bound.value = value
+		}
+	}
+
+	unit, err := requestUnit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if unit != nil {
+		writeJSON(w, formatSeries(s.Series(interval, from, to), *unit))
+		return
+	}
+
+	writeJSON(w, s.Series(interval, from, to))
+}
+
+// handleReconcile compares the tracked total supply with the known one posted in the body.
+// The `anchor=true` query parameter anchors the tracked total supply to it.
+func handleReconcile(w http.ResponseWriter, r *http.Request, s *tracker.State, allowAnchor bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	anchor := r.URL.Query().Get("anchor") == "true"
+	if anchor && !allowAnchor {
+		http.Error(w, "anchoring is not allowed", http.StatusForbidden)
+		return
+	}
+
+	var known tracker.KnownSupply
+	if err := json.NewDecoder(r.Body).Decode(&known); err != nil {
+		http.Error(w, fmt.Sprintf("invalid known supply: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.Reconcile(known, anchor)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	writeJSON(w, result)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
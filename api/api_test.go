@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+func newTestState() *tracker.State {
+	s := tracker.NewState()
+	s.BlockNumber = 1
+	s.Delta = big.NewInt(-1500000000)
+	s.Issuance.Reward = big.NewInt(1000000000)
+	s.Burn.EIP1559 = big.NewInt(2500000000)
+
+	return s
+}
+
+func get(t *testing.T, handler http.Handler, target string, header http.Header) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var body map[string]interface{}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+		}
+	}
+
+	return rec, body
+}
+
+func TestStateFormats(t *testing.T) {
+	handler := Handler(newTestState())
+
+	// Hex by default
+	_, body := get(t, handler, "/", nil)
+	if body["delta"] != "0x59682f00" || body["deltaSign"] != "-" {
+		t.Errorf("unexpected hex state: %v", body)
+	}
+
+	_, body = get(t, handler, "/?format=gwei", nil)
+	if body["delta"] != "-1.500000000" || body["unit"] != "gwei" {
+		t.Errorf("unexpected gwei state: %v", body)
+	}
+	if burn := body["burn"].(map[string]interface{}); burn["eip1559"] != "2.500000000" {
+		t.Errorf("unexpected gwei burn: %v", burn)
+	}
+	if _, ok := body["deltaSign"]; ok {
+		t.Errorf("decimal state has a deltaSign")
+	}
+
+	_, body = get(t, handler, "/", http.Header{"Accept": {"application/json; format=wei"}})
+	if body["delta"] != "-1500000000" {
+		t.Errorf("unexpected wei state: %v", body)
+	}
+
+	_, body = get(t, handler, "/?format=eth", nil)
+	if issuance := body["issuance"].(map[string]interface{}); issuance["reward"] != "0.000000001000000000" {
+		t.Errorf("unexpected eth issuance: %v", issuance)
+	}
+
+	if rec, _ := get(t, handler, "/?format=finney", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("want status %d for an unknown format, have %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestStateSchema(t *testing.T) {
+	handler := Handler(newTestState())
+
+	_, body := get(t, handler, "/?schema=2", nil)
+	if body["delta"] != "-0x59682f00" {
+		t.Errorf("unexpected signed delta: %v", body)
+	}
+	if _, ok := body["deltaSign"]; ok {
+		t.Errorf("schema 2 state has a deltaSign")
+	}
+
+	_, body = get(t, handler, "/", http.Header{"Accept": {"application/json; schema=1"}})
+	if body["delta"] != "0x59682f00" || body["deltaSign"] != "-" {
+		t.Errorf("unexpected schema 1 state: %v", body)
+	}
+
+	if rec, _ := get(t, handler, "/?schema=3", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("want status %d for an unknown schema, have %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestEras(t *testing.T) {
+	s := newTestState()
+	s.SetEras([]tracker.Era{{Name: "paris", From: 0}})
+	handler := Handler(s)
+
+	req := httptest.NewRequest(http.MethodGet, "/eras?format=wei", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var eras []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &eras); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	if len(eras) != 1 || eras[0]["name"] != "paris" || eras[0]["delta"] != "0" || eras[0]["unit"] != "wei" {
+		t.Errorf("unexpected eras: %v", eras)
+	}
+}
+
+func TestMetricsHideCommandLine(t *testing.T) {
+	rec, body := get(t, Handler(newTestState()), "/debug/vars", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want status %d, have %d", http.StatusOK, rec.Code)
+	}
+	for name := range hiddenVars {
+		if _, ok := body[name]; ok {
+			t.Errorf("want %s hidden, have %v", name, body[name])
+		}
+	}
+}
+
+func TestReconcileAnchorAuth(t *testing.T) {
+	anchor := func(handler http.Handler, header http.Header) int {
+		req := httptest.NewRequest(http.MethodPost, "/reconcile?anchor=true", strings.NewReader(`{"blockNumber": 1, "totalSupply": "0x0"}`))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := anchor(Handler(newTestState(), WithReconcile(true)), nil); code != http.StatusForbidden {
+		t.Errorf("want status %d without authentication, have %d", http.StatusForbidden, code)
+	}
+	public := Handler(newTestState(), WithReconcile(true), WithAuth(Auth{Tokens: []string{"a"}, Public: []string{"/reconcile"}}))
+	if code := anchor(public, nil); code != http.StatusForbidden {
+		t.Errorf("want status %d for a public reconciliation, have %d", http.StatusForbidden, code)
+	}
+
+	s := newTestState()
+	handler := Handler(s, WithReconcile(true), WithAuth(Auth{Tokens: []string{"a"}}))
+	if code := anchor(handler, nil); code != http.StatusUnauthorized {
+		t.Errorf("want status %d for an unauthenticated request, have %d", http.StatusUnauthorized, code)
+	}
+	if code := anchor(handler, http.Header{"Authorization": {"Bearer a"}}); code != http.StatusOK || s.Delta.Sign() != 0 {
+		t.Errorf("want the authenticated request anchored, have status %d and total %s", code, s.Delta)
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+// formatHex is the default format of the amounts, as hex big integers
+const formatHex = "hex"
+
+// requestParam returns the value of a response parameter, from the query parameter,
+// or the parameter of the Accept header, e.g. `Accept: application/json; format=eth`
+func requestParam(r *http.Request, name string) string {
+	if value := r.URL.Query().Get(name); value != "" {
+		return value
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && params[name] != "" {
+			return params[name]
+		}
+	}
+
+	return ""
+}
+
+// requestUnit returns the unit to render the amounts of the response with,
+// from the `format` parameter. It returns nil for the default hex format.
+func requestUnit(r *http.Request) (*supply.Unit, error) {
+	format := requestParam(r, "format")
+	if format == "" || strings.EqualFold(format, formatHex) {
+		return nil, nil
+	}
+
+	unit, err := supply.ParseUnit(format)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported format %q, want one of hex, wei, gwei, eth", format)
+	}
+
+	return &unit, nil
+}
+
+// requestSchema returns the schema version of the hex amounts of the response,
+// from the `schema` parameter. It defaults to version 1, with a `deltaSign`.
+func requestSchema(r *http.Request) (int, error) {
+	switch schema := requestParam(r, "schema"); schema {
+	case "", "1":
+		return tracker.SchemaV1, nil
+	case "2":
+		return tracker.SchemaV2, nil
+	default:
+		return 0, fmt.Errorf("unsupported schema %q, want one of 1, 2", schema)
+	}
+}
+
+// decimalCategories are the issuance or burn components with signed decimal amounts, by their JSON name
+type decimalCategories map[string]string
+
+// decimalTotalSupply is the total supply with signed decimal amounts of a unit
+type decimalTotalSupply struct {
+	BlockNumber uint64      `json:"blockNumber"`
+	Hash        common.Hash `json:"hash"`
+	ParentHash  common.Hash `json:"parentHash"`
+
+	Unit     string            `json:"unit"`
+	Delta    string            `json:"delta"`
+	Issuance decimalCategories `json:"issuance"`
+	Burn     decimalCategories `json:"burn"`
+}
+
+func formatTotalSupply(t tracker.TotalSupply, unit supply.Unit) decimalTotalSupply {
+	return decimalTotalSupply{
+		BlockNumber: t.BlockNumber,
+		Hash:        t.Hash,
+		ParentHash:  t.ParentHash,
+		Unit:        unit.Name,
+		Delta:       supply.FormatUnits(t.Delta, unit),
+		Issuance:    formatCategories(t.Issuance.Categories(), unit),
+		Burn:        formatCategories(t.Burn.Categories(), unit),
+	}
+}
+
+// decimalEraTotal is the total of an era with signed decimal amounts of a unit
+type decimalEraTotal struct {
+	tracker.Era
+
+	Unit     string            `json:"unit"`
+	Delta    string            `json:"delta"`
+	Issuance decimalCategories `json:"issuance"`
+	Burn     decimalCategories `json:"burn"`
+}
+
+func formatEraTotals(totals []tracker.EraTotal, unit supply.Unit) []decimalEraTotal {
+	out := make([]decimalEraTotal, 0, len(totals))
+	for _, t := range totals {
+		out = append(out, decimalEraTotal{
+			Era:      t.Era,
+			Unit:     unit.Name,
+			Delta:    supply.FormatUnits(t.Delta, unit),
+			Issuance: formatCategories(t.Issuance.Categories(), unit),
+			Burn:     formatCategories(t.Burn.Categories(), unit),
+		})
+	}
+
+	return out
+}
+
+// decimalBucket is a bucket of a series with signed decimal amounts of a unit
+type decimalBucket struct {
+	Start    uint64 `json:"start"`
+	Blocks   int    `json:"blocks"`
+	Unit     string `json:"unit"`
+	Issuance string `json:"issuance"`
+	Burn     string `json:"burn"`
+	Delta    string `json:"delta"`
+}
+
+func formatSeries(buckets []tracker.Bucket, unit supply.Unit) []decimalBucket {
+	out := make([]decimalBucket, 0, len(buckets))
+	for _, b := range buckets {
+		out = append(out, decimalBucket{
+			Start:    b.Start,
+			Blocks:   b.Blocks,
+			Unit:     unit.Name,
+			Issuance: supply.FormatUnits(b.Issuance, unit),
+			Burn:     supply.FormatUnits(b.Burn, unit),
+			Delta:    supply.FormatUnits(b.Delta, unit),
+		})
+	}
+
+	return out
+}
+
+// decimalRate is a supply rate with signed decimal amounts of a unit
+type decimalRate struct {
+	Window string `json:"window"`
+	From   uint64 `json:"from"`
+	To     uint64 `json:"to"`
+	Blocks int    `json:"blocks"`
+
+	Unit         string `json:"unit"`
+	Issuance     string `json:"issuance"`
+	Burn         string `json:"burn"`
+	Delta        string `json:"delta"`
+	IssuanceRate string `json:"issuanceRate"`
+	BurnRate     string `json:"burnRate"`
+	DeltaRate    string `json:"deltaRate"`
+
+	Inflation  *float64 `json:"inflation,omitempty"`
+	Ultrasound bool     `json:"ultrasound"`
+}
+
+func formatRates(rates []tracker.Rate, unit supply.Unit) []decimalRate {
+	out := make([]decimalRate, 0, len(rates))
+	for _, r := range rates {
+		out = append(out, decimalRate{
+			Window:       r.Window,
+			From:         r.From,
+			To:           r.To,
+			Blocks:       r.Blocks,
+			Unit:         unit.Name,
+			Issuance:     supply.FormatUnits(r.Issuance, unit),
+			Burn:         supply.FormatUnits(r.Burn, unit),
+			Delta:        supply.FormatUnits(r.Delta, unit),
+			IssuanceRate: supply.FormatUnits(r.IssuanceRate, unit),
+			BurnRate:     supply.FormatUnits(r.BurnRate, unit),
+			DeltaRate:    supply.FormatUnits(r.DeltaRate, unit),
+			Inflation:    r.Inflation,
+			Ultrasound:   r.Ultrasound,
+		})
+	}
+
+	return out
+}
+
+func formatCategories(cs []supply.Category, unit supply.Unit) decimalCategories {
+	out := make(decimalCategories, len(cs))
+	for _, c := range cs {
+		out[c.Name] = supply.FormatUnits(c.Amount, unit)
+	}
+
+	return out
+}
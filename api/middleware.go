@@ -0,0 +1,287 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SignatureHeader is the header carrying the HMAC-SHA256 signature of a request,
+	// in the form "sha256=<hex>", see SignRequest
+	SignatureHeader = "X-Supply-Signature"
+	// TimestampHeader is the header carrying the unix time a request was signed at
+	TimestampHeader = "X-Supply-Timestamp"
+
+	// maxSignatureAge is the maximum difference of the time of a signed request from the server time
+	maxSignatureAge = 5 * time.Minute
+	// maxSignedBody is the maximum size of the body of a signed request
+	maxSignedBody = 1 << 20
+
+	// sweepInterval is how often the rate limiter forgets the clients with a full bucket
+	sweepInterval = time.Minute
+	// corsMaxAge is how long browsers can cache the preflight responses, in seconds
+	corsMaxAge = "600"
+)
+
+// Auth configures the authentication of the requests.
+// A request is authenticated by any of the tokens, or by a signature with the secret.
+type Auth struct {
+	Tokens []string // Bearer tokens of the Authorization header
+	Secret string   // Secret of the HMAC-SHA256 signatures of the SignatureHeader, none when empty
+
+	Public []string // Paths served without authentication, e.g. "/"
+}
+
+// RateLimit configures the rate limiting of the requests of each client
+type RateLimit struct {
+	Rate  float64 // Requests per second
+	Burst int     // Requests that can be made at once
+
+	// Header identifying the client, e.g. "X-Forwarded-For" behind a proxy. Its right-most value,
+	// appended by the proxy, is used, as the values before it are sent by the client.
+	// The remote address of the connection is used when empty, or when the header is missing.
+	// The connections of a unix socket have no address, so all their clients share a single limit.
+	ClientHeader string
+}
+
+// SignRequest returns the signature of a request for the SignatureHeader. It signs the
+// timestamp of the TimestampHeader, the method, the request URI with its query, and the body.
+func SignRequest(secret string, timestamp int64, method, uri string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d\n%s\n%s\n", timestamp, method, uri)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// withAuth rejects the unauthenticated requests of the non public paths
+func withAuth(next http.Handler, auth Auth) http.Handler {
+	public := make(map[string]bool, len(auth.Public))
+	for _, path := range auth.Public {
+		public[path] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if public[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if token, ok := bearerToken(r); ok && len(auth.Tokens) > 0 {
+			if validToken(auth.Tokens, token) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		} else if r.Header.Get(SignatureHeader) != "" && auth.Secret != "" {
+			if err := verifySignature(r, auth.Secret, time.Now()); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(auth.Tokens) > 0 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[len("Bearer "):]), true
+}
+
+// validToken compares the token with all the tokens in constant time
+func validToken(tokens []string, token string) bool {
+	valid := 0
+	for _, t := range tokens {
+		valid |= subtle.ConstantTimeCompare([]byte(t), []byte(token))
+	}
+	return valid == 1
+}
+
+// verifySignature checks the signature of the request, and that it was signed recently.
+// The body is read, and replaced for the next handler.
+func verifySignature(r *http.Request, secret string, now time.Time) error {
+	timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s", TimestampHeader)
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+		return fmt.Errorf("expired %s", TimestampHeader)
+	}
+
+	var body []byte
+	if r.Body != nil {
+		if body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBody+1)); err != nil {
+			return fmt.Errorf("failed to read body: %v", err)
+		}
+		if len(body) > maxSignedBody {
+			return fmt.Errorf("body larger than %d bytes", maxSignedBody)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	want := SignRequest(secret, timestamp, r.Method, r.URL.RequestURI(), body)
+	if !hmac.Equal([]byte(want), []byte(r.Header.Get(SignatureHeader))) {
+		return fmt.Errorf("invalid %s", SignatureHeader)
+	}
+
+	return nil
+}
+
+// rateLimiter limits the requests of each client with a token bucket
+type rateLimiter struct {
+	limit RateLimit
+	now   func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time // Time the tokens were last refilled
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+
+	return &rateLimiter{
+		limit:   limit,
+		now:     time.Now,
+		clients: make(map[string]*bucket),
+	}
+}
+
+// allow takes a token of the client, or returns how long to wait for one
+func (l *rateLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.clients[client] = b
+	}
+
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+	}
+	b.tokens--
+
+	return true, 0
+}
+
+// sweep forgets the clients whose bucket has been refilled, so that they do not accumulate
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	refill := time.Duration(float64(l.limit.Burst) / l.limit.Rate * float64(time.Second))
+	for client, b := range l.clients {
+		if now.Sub(b.last) >= refill {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// client returns the key of the client of the request
+func (l *rateLimiter) client(r *http.Request) string {
+	if l.limit.ClientHeader != "" {
+		// The header can be repeated, and the proxy appends to the last one
+		if values := r.Header.Values(l.limit.ClientHeader); len(values) > 0 {
+			last := values[len(values)-1]
+			if i := strings.LastIndex(last, ","); i >= 0 {
+				last = last[i+1:]
+			}
+			if client := strings.TrimSpace(last); client != "" {
+				return client
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// withRateLimit rejects the requests of the clients over the rate limit
+func withRateLimit(next http.Handler, l *rateLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.allow(l.client(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withCORS allows cross-origin requests from the origins, "*" allowing any origin.
+// Preflight requests are answered without being passed to the next handler.
+func withCORS(next http.Handler, origins []string) http.Handler {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		switch {
+		case allowed["*"]:
+			h.Set("Access-Control-Allow-Origin", "*")
+		case allowed[origin]:
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if h.Get("Access-Control-Allow-Origin") != "" {
+				h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				h.Set("Access-Control-Allow-Headers", strings.Join([]string{"Authorization", "Content-Type", "Accept", SignatureHeader, TimestampHeader}, ", "))
+				h.Set("Access-Control-Max-Age", corsMaxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthToken(t *testing.T) {
+	handler := Handler(newTestState(), WithAuth(Auth{Tokens: []string{"a", "b"}, Public: []string{"/debug/vars"}}))
+
+	if rec, _ := get(t, handler, "/", nil); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("want status %d with a challenge, have %d", http.StatusUnauthorized, rec.Code)
+	}
+	if rec, _ := get(t, handler, "/", http.Header{"Authorization": {"Bearer c"}}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("want status %d for an invalid token, have %d", http.StatusUnauthorized, rec.Code)
+	}
+	if rec, _ := get(t, handler, "/", http.Header{"Authorization": {"Bearer b"}}); rec.Code != http.StatusOK {
+		t.Errorf("want status %d for a valid token, have %d", http.StatusOK, rec.Code)
+	}
+	if rec, _ := get(t, handler, "/debug/vars", nil); rec.Code != http.StatusOK {
+		t.Errorf("want status %d for a public path, have %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestAuthSignature(t *testing.T) {
+	const secret = "secret"
+	handler := Handler(newTestState(), WithReconcile(false), WithAuth(Auth{Secret: secret}))
+
+	signed := func(method, target, body string, timestamp int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(SignatureHeader, SignRequest(secret, timestamp, method, target, []byte(body)))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	now := time.Now().Unix()
+	if rec := signed(http.MethodGet, "/?format=wei", "", now); rec.Code != http.StatusOK {
+		t.Errorf("want status %d for a signed request, have %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if rec := signed(http.MethodGet, "/", "", now-int64(maxSignatureAge/time.Second)-60); rec.Code != http.StatusUnauthorized {
+		t.Errorf("want status %d for an expired signature, have %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	// The body reaches the handler after verifying its signature
+	body := `{"blockNumber": 1, "totalSupply": "0x0"}`
+	if rec := signed(http.MethodPost, "/reconcile", body, now); rec.Code != http.StatusOK {
+		t.Errorf("want status %d for a signed post, have %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	// A signature of another query is rejected
+	req := httptest.NewRequest(http.MethodGet, "/?format=eth", nil)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now, 10))
+	req.Header.Set(SignatureHeader, SignRequest(secret, now, http.MethodGet, "/?format=wei", nil))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("want status %d for a signature of another query, have %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newRateLimiter(RateLimit{Rate: 1, Burst: 2, ClientHeader: "X-Forwarded-For"})
+	l.now = func() time.Time { return now }
+	handler := withRateLimit(Handler(newTestState()), l)
+
+	request := func(client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Forwarded-For", "10.0.0.1, "+client)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := request("1.1.1.1"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: want status %d within the burst, have %d", i, http.StatusOK, rec.Code)
+		}
+	}
+	rec := request("1.1.1.1")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("want status %d with Retry-After 1, have %d with %q", http.StatusTooManyRequests, rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := request("2.2.2.2"); rec.Code != http.StatusOK {
+		t.Errorf("want status %d for another client, have %d", http.StatusOK, rec.Code)
+	}
+
+	// The values sent by the client before the one of the proxy are ignored
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-For", "9.9.9.9, 1.1.1.1")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("want status %d for a forged client, have %d", http.StatusTooManyRequests, rec.Code)
+	}
+
+	now = now.Add(time.Second)
+	if rec := request("1.1.1.1"); rec.Code != http.StatusOK {
+		t.Errorf("want status %d after the refill, have %d", http.StatusOK, rec.Code)
+	}
+
+	// Idle clients are forgotten
+	now = now.Add(sweepInterval)
+	request("3.3.3.3")
+	if len(l.clients) != 1 {
+		t.Errorf("want 1 client after the sweep, have %d", len(l.clients))
+	}
+}
+
+func TestCORS(t *testing.T) {
+	handler := Handler(newTestState(), WithCORS([]string{"https://example.com"}), WithAuth(Auth{Tokens: []string{"a"}}))
+
+	// Preflight requests are answered without credentials
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+		t.Errorf("unexpected preflight response %d: %v", rec.Code, rec.Header())
+	}
+	if !strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Errorf("want Authorization allowed, have %q", rec.Header().Get("Access-Control-Allow-Headers"))
+	}
+
+	rec, _ = get(t, handler, "/", http.Header{"Origin": {"https://example.com"}, "Authorization": {"Bearer a"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+		t.Errorf("unexpected response %d: %v", rec.Code, rec.Header())
+	}
+
+	rec, _ = get(t, handler, "/", http.Header{"Origin": {"https://other.com"}, "Authorization": {"Bearer a"}})
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("want other origins not allowed, have %q", rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
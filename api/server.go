@@ -0,0 +1,169 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+// Default timeouts of the API server
+const (
+	DefaultReadTimeout  = 10 * time.Second
+	DefaultWriteTimeout = 30 * time.Second
+	DefaultIdleTimeout  = 2 * time.Minute
+)
+
+// ServerConfig configures the listeners and the timeouts of the API server
+type ServerConfig struct {
+	Addr   string // host:port to listen on, none when empty
+	Socket string // Path of a unix domain socket to listen on, none when empty
+
+	// TLS certificate and key files of the Addr listener, plain HTTP when empty
+	CertFile string
+	KeyFile  string
+
+	// Zero timeouts use the defaults
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
+
+// Server serves the API on a TCP address, a unix domain socket or both
+type Server struct {
+	config ServerConfig
+	server *http.Server
+
+	tcp  net.Listener
+	unix net.Listener
+}
+
+// NewServer returns the API server of the state, configured by config
+func NewServer(config ServerConfig, s *tracker.State, opts ...Option) (*Server, error) {
+	if config.Addr == "" && config.Socket == "" {
+		return nil, errors.New("no address or unix socket to listen on")
+	}
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return nil, errors.New("both the TLS certificate and key files are required")
+	}
+	if o := newOptions(opts); o.allowAnchor && !o.anchorAllowed() {
+		return nil, errors.New("anchoring requires the authentication of the /reconcile requests")
+	}
+
+	if config.ReadTimeout == 0 {
+		config.ReadTimeout = DefaultReadTimeout
+	}
+	if config.WriteTimeout == 0 {
+		config.WriteTimeout = DefaultWriteTimeout
+	}
+	if config.IdleTimeout == 0 {
+		config.IdleTimeout = DefaultIdleTimeout
+	}
+
+	return &Server{
+		config: config,
+		server: &http.Server{
+			Handler:           Handler(s, opts...),
+			ReadHeaderTimeout: config.ReadTimeout,
+			ReadTimeout:       config.ReadTimeout,
+			WriteTimeout:      config.WriteTimeout,
+			IdleTimeout:       config.IdleTimeout,
+		},
+	}, nil
+}
+
+// Listen opens the listeners of the server. A stale unix socket file,
+// left by a previous run, is removed.
+func (srv *Server) Listen() error {
+	if srv.config.Addr != "" {
+		l, err := net.Listen("tcp", srv.config.Addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %v", srv.config.Addr, err)
+		}
+		srv.tcp = l
+	}
+
+	if srv.config.Socket != "" {
+		if info, err := os.Stat(srv.config.Socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(srv.config.Socket)
+		}
+		l, err := net.Listen("unix", srv.config.Socket)
+		if err != nil {
+			srv.Close()
+			return fmt.Errorf("failed to listen on unix socket %s: %v", srv.config.Socket, err)
+		}
+		srv.unix = l
+	}
+
+	return nil
+}
+
+// Addr returns the address of the TCP listener, nil when there is none
+func (srv *Server) Addr() net.Addr {
+	if srv.tcp == nil {
+		return nil
+	}
+	return srv.tcp.Addr()
+}
+
+// Serve serves the API on the listeners until one of them fails, or the server is closed.
+// Listen has to be called before.
+func (srv *Server) Serve() error {
+	errCh := make(chan error, 2)
+
+	if srv.tcp != nil {
+		useTLS := srv.config.CertFile != ""
+		scheme := "http"
+		if useTLS {
+			scheme = "https"
+		}
+		log.Printf("Starting server on %s://%s\n", scheme, srv.tcp.Addr())
+
+		go func() {
+			if useTLS {
+				errCh <- srv.server.ServeTLS(srv.tcp, srv.config.CertFile, srv.config.KeyFile)
+			} else {
+				errCh <- srv.server.Serve(srv.tcp)
+			}
+		}()
+	}
+
+	if srv.unix != nil {
+		log.Printf("Starting server on unix socket %s\n", srv.config.Socket)
+		go func() {
+			errCh <- srv.server.Serve(srv.unix)
+		}()
+	}
+
+	err := <-errCh
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	srv.Close()
+
+	return err
+}
+
+// ListenAndServe opens the listeners of the server and serves the API on them
+func (srv *Server) ListenAndServe() error {
+	if err := srv.Listen(); err != nil {
+		return err
+	}
+	return srv.Serve()
+}
+
+// Close closes the listeners and the connections of the server
+func (srv *Server) Close() error {
+	err := srv.server.Close()
+	for _, l := range []net.Listener{srv.tcp, srv.unix} {
+		if l != nil {
+			l.Close()
+		}
+	}
+	return err
+}
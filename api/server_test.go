@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startServer serves the test state with config, until the test ends
+func startServer(t *testing.T, config ServerConfig) *Server {
+	t.Helper()
+
+	srv, err := NewServer(config, newTestState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Listen(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve() }()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+
+	return srv
+}
+
+func expectBlock(t *testing.T, client *http.Client, url string) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want status %d, have %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestServerUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+
+	// A socket file left by a previous run is replaced
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	srv := startServer(t, ServerConfig{Socket: socket})
+	if srv.Addr() != nil {
+		t.Errorf("want no TCP listener, have %s", srv.Addr())
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	expectBlock(t, client, "http://unix/")
+}
+
+func TestServerTLS(t *testing.T) {
+	certFile, keyFile, pool := writeTestCert(t)
+
+	srv := startServer(t, ServerConfig{Addr: "127.0.0.1:0", CertFile: certFile, KeyFile: keyFile})
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	expectBlock(t, client, "https://"+srv.Addr().String()+"/")
+
+	// Plain HTTP is not served
+	resp, err := http.Get("http://" + srv.Addr().String() + "/")
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Errorf("want plain HTTP refused")
+		}
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	for _, config := range []ServerConfig{
+		{},
+		{Addr: ":0", CertFile: "cert.pem"},
+		{Addr: ":0", KeyFile: "key.pem"},
+	} {
+		if _, err := NewServer(config, newTestState()); err == nil {
+			t.Errorf("want error for %+v", config)
+		}
+	}
+
+	// Anchoring requires authenticated reconciliations
+	for _, opts := range [][]Option{
+		{WithReconcile(true)},
+		{WithReconcile(true), WithAuth(Auth{Tokens: []string{"a"}, Public: []string{"/reconcile"}})},
+	} {
+		if _, err := NewServer(ServerConfig{Addr: ":0"}, newTestState(), opts...); err == nil {
+			t.Errorf("want error for anchoring without authentication")
+		}
+	}
+
+	srv, err := NewServer(ServerConfig{Addr: ":0", WriteTimeout: time.Minute}, newTestState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.server.ReadTimeout != DefaultReadTimeout || srv.server.WriteTimeout != time.Minute || srv.server.IdleTimeout != DefaultIdleTimeout {
+		t.Errorf("unexpected timeouts %v, %v, %v", srv.server.ReadTimeout, srv.server.WriteTimeout, srv.server.IdleTimeout)
+	}
+}
+
+// writeTestCert writes a self-signed certificate of 127.0.0.1 and its key,
+// and returns their files and a pool trusting the certificate
+func writeTestCert(t *testing.T) (string, string, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return certFile, keyFile, pool
+}
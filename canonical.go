@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ziogaschr/supply-tracer-parser/reader"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+// canonicalBlocks reads the canonical blocks of a set of supply files in order,
+// once they can no longer be reorged. The reorgs are handled as the tracker does.
+type canonicalBlocks struct {
+	state      *tracker.State
+	eventsCh   <-chan reader.Event
+	errCh      chan error
+	timestamps map[uint64]uint64 // Timestamps of the blocks written without one
+
+	started bool
+	done    bool
+	number  uint64 // Number of the next canonical block
+	pending []supply.Info
+}
+
+func newCanonicalBlocks(path string, timestamps map[uint64]uint64) (*canonicalBlocks, error) {
+	eventsCh, err := reader.ReadFiles(path, "", true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &canonicalBlocks{state: tracker.NewState(), eventsCh: eventsCh, errCh: make(chan error, 16), timestamps: timestamps}, nil
+}
+
+// next returns the next canonical block, or false when all have been read
+func (c *canonicalBlocks) next() (supply.Info, bool, error) {
+	for len(c.pending) == 0 {
+		if c.done {
+			return supply.Info{}, false, nil
+		}
+
+		event, ok := <-c.eventsCh
+		if !ok {
+			c.done = true
+			if c.started {
+				if err := c.collect(c.state.BlockNumber); err != nil {
+					return supply.Info{}, false, err
+				}
+			}
+			continue
+		}
+
+		switch event.Kind {
+		case reader.EventBlock:
+			fillTimestamp(&event.Supply, c.timestamps)
+			if !c.started {
+				c.started = true
+				c.number = event.Supply.Number
+			}
+
+			c.state.HandleEntry(event.Supply, c.errCh)
+			if len(c.errCh) > 0 {
+				return supply.Info{}, false, fmt.Errorf("%v\n\tat %s", <-c.errCh, event.Pos)
+			}
+
+			if finalized, ok := c.state.FinalizedNumber(); ok {
+				if err := c.collect(finalized); err != nil {
+					return supply.Info{}, false, err
+				}
+			}
+		case reader.EventError:
+			return supply.Info{}, false, fmt.Errorf("%v\n\tat %s", event.Err, event.Pos)
+		}
+	}
+
+	entry := c.pending[0]
+	c.pending = c.pending[1:]
+
+	return entry, true, nil
+}
+
+// collect queues the canonical blocks up to number
+func (c *canonicalBlocks) collect(number uint64) error {
+	for ; c.number <= number; c.number++ {
+		entry, ok := c.state.CanonicalEntry(c.number)
+		if !ok {
+			return fmt.Errorf("block %d is not in history", c.number)
+		}
+		c.pending = append(c.pending, entry)
+	}
+
+	return nil
+}
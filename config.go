@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is the prefix of the environment variables of the options,
+// e.g. SUPPLY_TRACER_SUPPLY_FILE for --supply.file
+const envPrefix = "SUPPLY_TRACER_"
+
+// secretOptions are redacted in the dumped configuration
+var secretOptions = map[string]bool{
+	webhookSecretFlag.Name: true,
+	apiAuthTokenFlag.Name:  true,
+	apiAuthSecretFlag.Name: true,
+}
+
+var configCommand = &cli.Command{
+	Name:  "config",
+	Usage: "Inspect the configuration",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "dump",
+			Usage: "Print the effective configuration of the service as YAML",
+			Description: `Prints the options of the service after applying the config file, the environment variables and the flags,
+in a format that can be used as config file. Secrets are redacted.`,
+			Action: dumpConfig,
+		},
+	},
+}
+
+// envVar returns the environment variable of the option
+func envVar(name string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
+
+// withConfig makes the options of the app and its commands configurable by the config file,
+// and by environment variables. Flags override the environment variables, which override the config file.
+// The options of a command, that the app does not have, are prefixed with the name of the command,
+// e.g. export.format. The flags and commands are copied, so that the app can be created again.
+func withConfig(app *cli.App) *cli.App {
+	shared := make(map[string]bool, len(app.Flags))
+	for _, f := range app.Flags {
+		shared[f.Names()[0]] = true
+	}
+
+	app.Flags = configurable(app.Flags, "", shared)
+	app.Before = altsrc.InitInputSourceWithContext(app.Flags, configSource("", shared))
+
+	for i, cmd := range app.Commands {
+		c := *cmd
+		c.Flags = configurable(cmd.Flags, cmd.Name, shared)
+		c.Before = altsrc.InitInputSourceWithContext(c.Flags, configSource(cmd.Name, shared))
+		app.Commands[i] = &c
+	}
+
+	return app
+}
+
+// configName returns the name of the option of the flag of the command, none for the app
+func configName(command, name string, shared map[string]bool) string {
+	if command == "" || shared[name] {
+		return name
+	}
+	return command + "." + name
+}
+
+// configurable returns copies of the flags, wrapped to be set by an input source,
+// with the environment variables of their options
+func configurable(flags []cli.Flag, command string, shared map[string]bool) []cli.Flag {
+	wrapped := make([]cli.Flag, 0, len(flags))
+	for _, f := range flags {
+		if f == cli.HelpFlag {
+			wrapped = append(wrapped, f)
+			continue
+		}
+		env := []string{envVar(configName(command, f.Names()[0], shared))}
+
+		switch f := f.(type) {
+		case *cli.StringFlag:
+			c := *f
+			c.EnvVars = env
+			wrapped = append(wrapped, altsrc.NewStringFlag(&c))
+		case *cli.StringSliceFlag:
+			c := *f
+			c.EnvVars = env
+			wrapped = append(wrapped, altsrc.NewStringSliceFlag(&c))
+		case *cli.IntFlag:
+			c := *f
+			c.EnvVars = env
+			wrapped = append(wrapped, altsrc.NewIntFlag(&c))
+		case *cli.Float64Flag:
+			c := *f
+			c.EnvVars = env
+			wrapped = append(wrapped, altsrc.NewFloat64Flag(&c))
+		case *cli.Uint64Flag:
+			c := *f
+			c.EnvVars = env
+			wrapped = append(wrapped, altsrc.NewUint64Flag(&c))
+		case *cli.BoolFlag:
+			c := *f
+			c.EnvVars = env
+			wrapped = append(wrapped, altsrc.NewBoolFlag(&c))
+		case *cli.DurationFlag:
+			c := *f
+			c.EnvVars = env
+			wrapped = append(wrapped, altsrc.NewDurationFlag(&c))
+		default:
+			wrapped = append(wrapped, f)
+		}
+	}
+
+	return wrapped
+}
+
+// configSource returns the input source of the options of the command, none for the app,
+// from the config file of the --config flag. The options of a command are read from
+// its section, e.g. `export.format`, and the options it shares with the app from the top level.
+func configSource(command string, shared map[string]bool) func(*cli.Context) (altsrc.InputSourceContext, error) {
+	return func(ctx *cli.Context) (altsrc.InputSourceContext, error) {
+		path := ctx.String(configFileFlag.Name)
+		if path == "" {
+			return altsrc.NewMapInputSource("", map[interface{}]interface{}{}), nil
+		}
+
+		config, err := loadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		if command == "" {
+			return altsrc.NewMapInputSource(path, config), nil
+		}
+
+		options := make(map[interface{}]interface{})
+		for name := range shared {
+			if value, ok := lookupConfig(config, name); ok {
+				options[name] = value
+			}
+		}
+		if section, ok := config[command].(map[interface{}]interface{}); ok {
+			for name, value := range section {
+				options[name] = value
+			}
+		}
+		for key, value := range config {
+			if name, ok := key.(string); ok && strings.HasPrefix(name, command+".") {
+				options[strings.TrimPrefix(name, command+".")] = value
+			}
+		}
+
+		return altsrc.NewMapInputSource(path, options), nil
+	}
+}
+
+// loadConfig reads the YAML or TOML config file, by its extension, with its tables as nested maps
+func loadConfig(path string) (map[interface{}]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var config map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &config)
+	case ".yaml", ".yml", "":
+		err = yaml.Unmarshal(data, &config)
+	default:
+		return nil, fmt.Errorf("unknown config file format %q, want .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	return sourceMap(config), nil
+}
+
+// sourceMap converts a decoded table to the map of an input source, which expects
+// the nested tables as maps of interfaces and the integers as int
+func sourceMap(table map[string]interface{}) map[interface{}]interface{} {
+	m := make(map[interface{}]interface{}, len(table))
+	for key, value := range table {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			m[key] = sourceMap(value)
+		case int64:
+			m[key] = int(value)
+		default:
+			m[key] = value
+		}
+	}
+
+	return m
+}
+
+// lookupConfig returns the value of the option, by its name or nested by its sections,
+// e.g. `api: {port: 8080}` for api.port
+func lookupConfig(config map[interface{}]interface{}, name string) (interface{}, bool) {
+	if value, ok := config[name]; ok {
+		return value, true
+	}
+
+	sections := strings.Split(name, ".")
+	node := config
+	for _, section := range sections[:len(sections)-1] {
+		child, ok := node[section].(map[interface{}]interface{})
+		if !ok {
+			return nil, false
+		}
+		node = child
+	}
+	value, ok := node[sections[len(sections)-1]]
+
+	return value, ok
+}
+
+func dumpConfig(ctx *cli.Context) error {
+	config := make(map[string]interface{})
+	for _, f := range ctx.App.Flags {
+		name := f.Names()[0]
+		if name == configFileFlag.Name || name == "help" {
+			continue
+		}
+
+		if secretOptions[name] && ctx.IsSet(name) {
+			config[name] = "<redacted>"
+			continue
+		}
+
+		switch value := ctx.Value(name).(type) {
+		case cli.StringSlice:
+			config[name] = value.Value()
+		case *cli.StringSlice:
+			config[name] = value.Value()
+		case time.Duration:
+			config[name] = value.String()
+		default:
+			config[name] = value
+		}
+	}
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the configuration: %v", err)
+	}
+	fmt.Fprint(ctx.App.Writer, string(out))
+
+	return nil
+}
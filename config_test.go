@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+func dumpTestConfig(t *testing.T, args ...string) map[string]interface{} {
+	t.Helper()
+
+	var out bytes.Buffer
+	app := newApp()
+	app.Writer = &out
+	if err := app.Run(append(append([]string{"supply-tracer-parser"}, args...), "config", "dump")); err != nil {
+		t.Fatal(err)
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(out.Bytes(), &config); err != nil {
+		t.Fatalf("invalid dump: %v\n%s", err, out.String())
+	}
+	return config
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `supply.file: /data/supply.jsonl
+api:
+  port: 9000
+history.limit: 32
+webhook.url: [http://a, http://b]
+webhook.stall: 5m
+webhook.secret: secret
+`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SUPPLY_TRACER_API_PORT", "9100")
+	t.Setenv("SUPPLY_TRACER_HISTORY_LIMIT", "48")
+	t.Setenv("SUPPLY_TRACER_API_AUTH_TOKEN", "a,b")
+	t.Setenv("SUPPLY_TRACER_API_RATELIMIT_RATE", "2.5")
+
+	dump := dumpTestConfig(t, "--config", path, "--history.limit", "64")
+
+	want := map[string]interface{}{
+		"supply.file":        "/data/supply.jsonl", // config file
+		"api.port":           9100,                 // environment variable over config file
+		"history.limit":      64,                   // flag over environment variable
+		"state.file":         "state.json",         // default
+		"webhook.stall":      "5m0s",
+		"webhook.secret":     "<redacted>",
+		"validation.policy":  "log",
+		"api.auth.token":     "<redacted>",
+		"api.ratelimit.rate": 2.5,
+	}
+	for name, value := range want {
+		if dump[name] != value {
+			t.Errorf("%s: want %v, have %v", name, value, dump[name])
+		}
+	}
+	if urls, ok := dump["webhook.url"].([]interface{}); !ok || len(urls) != 2 {
+		t.Errorf("webhook.url: want 2 urls, have %v", dump["webhook.url"])
+	}
+	if _, ok := dump["config"]; ok {
+		t.Errorf("want the config file option omitted")
+	}
+}
+
+func TestConfigTOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	config := `"eras.network" = "sepolia"
+
+[supply]
+file = "/data/supply.jsonl"
+`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dump := dumpTestConfig(t, "--config", path)
+	if dump["eras.network"] != "sepolia" || dump["supply.file"] != "/data/supply.jsonl" {
+		t.Errorf("unexpected dump %v", dump)
+	}
+}
+
+func TestConfigCommandOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `supply.file: /data/supply.jsonl
+from: 7
+export:
+  format: parquet
+export.to: 9
+`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SUPPLY_TRACER_EXPORT_OUTPUT", "out.parquet")
+
+	var supplyFile, format, output string
+	var from, to uint64
+	app := newApp()
+	for _, cmd := range app.Commands {
+		if cmd.Name == exportCommand.Name {
+			cmd.Action = func(ctx *cli.Context) error {
+				supplyFile, format, output = ctx.String(supplyFileFlag.Name), ctx.String(exportFormatFlag.Name), ctx.String(exportOutputFlag.Name)
+				from, to = ctx.Uint64(exportFromFlag.Name), ctx.Uint64(exportToFlag.Name)
+				return nil
+			}
+		}
+	}
+	if err := app.Run([]string{"supply-tracer-parser", "--config", path, "export"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The options shared with the app are read from the top level, the others from the section of the command
+	if supplyFile != "/data/supply.jsonl" || format != "parquet" || output != "out.parquet" || from != 0 || to != 9 {
+		t.Errorf("unexpected export options %q %q %q %d %d", supplyFile, format, output, from, to)
+	}
+	if exportFormatFlag.EnvVars != nil || supplyFileFlag.EnvVars != nil {
+		t.Errorf("want the flags of the package unchanged, have %v %v", exportFormatFlag.EnvVars, supplyFileFlag.EnvVars)
+	}
+	if exportCommand.Before != nil {
+		t.Errorf("want the commands of the package unchanged")
+	}
+}
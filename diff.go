@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/urfave/cli/v2"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+var (
+	diffJSONFlag = &cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the differences as JSON",
+	}
+
+	diffCommand = &cli.Command{
+		Name:  "diff",
+		Usage: "Compare two state files, or two sets of supply files",
+		Subcommands: []*cli.Command{
+			{
+				Name:        "state",
+				Usage:       "Compare the total supply of two state files",
+				ArgsUsage:   "<a> <b>",
+				Description: `Prints the total supply delta and every issuance and burn component of both state files, and their difference b - a.`,
+				Flags:       []cli.Flag{diffJSONFlag},
+				Action:      diffStates,
+			},
+			{
+				Name:      "traces",
+				Usage:     "Compare two supply files and their log rotated files",
+				ArgsUsage: "<a> <b>",
+				Description: `Reads both sets of supply files once, handling the reorgs as the tracker does, and compares their canonical blocks
+up to the first one that diverges. Prints that block and its differences, and the total supply of both sets as for state files.`,
+				Flags:  []cli.Flag{diffJSONFlag},
+				Action: diffTraces,
+			},
+		},
+	}
+)
+
+// componentDiff is a component of the total supply of both sides, and their difference b - a
+type componentDiff struct {
+	Name       string       `json:"name"`
+	A          *hexutil.Big `json:"a"`
+	B          *hexutil.Big `json:"b"`
+	Difference *hexutil.Big `json:"difference"`
+}
+
+// supplyDiff is the difference of two total supplies
+type supplyDiff struct {
+	A          tracker.BlockRef `json:"a"`
+	B          tracker.BlockRef `json:"b"`
+	Components []componentDiff  `json:"components"`
+	Equal      bool             `json:"equal"`
+}
+
+// traceDiff is the difference of two sets of supply files
+type traceDiff struct {
+	supplyDiff
+	Compared   int              `json:"compared"`             // Number of canonical blocks compared
+	Divergence *blockDivergence `json:"divergence,omitempty"` // First canonical block that diverges
+}
+
+// blockDivergence is a canonical block that differs between two sets of supply files
+type blockDivergence struct {
+	BlockNumber uint64   `json:"blockNumber"`
+	Differences []string `json:"differences"`
+}
+
+func diffArgs(ctx *cli.Context) (string, string, error) {
+	if ctx.NArg() != 2 {
+		return "", "", errors.New("expected two paths to compare")
+	}
+	return ctx.Args().Get(0), ctx.Args().Get(1), nil
+}
+
+func diffStates(ctx *cli.Context) error {
+	pathA, pathB, err := diffArgs(ctx)
+	if err != nil {
+		return err
+	}
+
+	a, b := tracker.NewState(), tracker.NewState()
+	if _, err := a.LoadState(pathA); err != nil {
+		return err
+	}
+	if _, err := b.LoadState(pathB); err != nil {
+		return err
+	}
+
+	diff := compareSupply(a.Snapshot(), b.Snapshot())
+	if err := printDiff(ctx, &diff, func(w io.Writer) { printSupplyDiff(w, &diff) }); err != nil {
+		return err
+	}
+	if !diff.Equal {
+		return errors.New("the state files differ")
+	}
+
+	return nil
+}
+
+func diffTraces(ctx *cli.Context) error {
+	pathA, pathB, err := diffArgs(ctx)
+	if err != nil {
+		return err
+	}
+
+	diff, err := compareTraces(pathA, pathB)
+	if err != nil {
+		return err
+	}
+	if err := printDiff(ctx, diff, func(w io.Writer) { printTraceDiff(w, diff) }); err != nil {
+		return err
+	}
+	if !diff.Equal {
+		return errors.New("the supply files differ")
+	}
+
+	return nil
+}
+
+func printDiff(ctx *cli.Context, diff interface{}, print func(w io.Writer)) error {
+	if !ctx.Bool(diffJSONFlag.Name) {
+		print(os.Stdout)
+		return nil
+	}
+
+	out, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal differences: %v", err)
+	}
+	fmt.Fprintln(os.Stdout, string(out))
+
+	return nil
+}
+
+// compareSupply compares the total supply delta and the components of two total supplies
+func compareSupply(a, b tracker.TotalSupply) supplyDiff {
+	diff := supplyDiff{
+		A:          tracker.BlockRef{Number: a.BlockNumber, Hash: a.Hash},
+		B:          tracker.BlockRef{Number: b.BlockNumber, Hash: b.Hash},
+		Components: []componentDiff{newComponentDiff("delta", a.Delta, b.Delta)},
+	}
+	diff.Components = append(diff.Components, compareCategories("issuance ", a.Issuance.Categories(), b.Issuance.Categories())...)
+	diff.Components = append(diff.Components, compareCategories("burn ", a.Burn.Categories(), b.Burn.Categories())...)
+
+	diff.Equal = diff.A == diff.B
+	for _, c := range diff.Components {
+		if c.Difference.ToInt().Sign() != 0 {
+			diff.Equal = false
+		}
+	}
+
+	return diff
+}
+
+// compareCategories compares the categories of both sides by name, the missing ones being zero
+func compareCategories(prefix string, a, b []supply.Category) []componentDiff {
+	var names []string
+	amountsA, amountsB := make(map[string]*big.Int), make(map[string]*big.Int)
+	for _, c := range a {
+		names = append(names, c.Name)
+		amountsA[c.Name] = c.Amount
+	}
+	for _, c := range b {
+		if _, ok := amountsA[c.Name]; !ok {
+			names = append(names, c.Name)
+		}
+		amountsB[c.Name] = c.Amount
+	}
+
+	diffs := make([]componentDiff, 0, len(names))
+	for _, name := range names {
+		diffs = append(diffs, newComponentDiff(prefix+name, amountsA[name], amountsB[name]))
+	}
+
+	return diffs
+}
+
+func newComponentDiff(name string, a, b *big.Int) componentDiff {
+	if a == nil {
+		a = new(big.Int)
+	}
+	if b == nil {
+		b = new(big.Int)
+	}
+
+	return componentDiff{
+		Name:       name,
+		A:          (*hexutil.Big)(a),
+		B:          (*hexutil.Big)(b),
+		Difference: (*hexutil.Big)(new(big.Int).Sub(b, a)),
+	}
+}
+
+// compareTraces compares the canonical blocks of two sets of supply files, and their total supply
+func compareTraces(pathA, pathB string) (*traceDiff, error) {
+	a, err := newCanonicalBlocks(pathA, nil)
+	if err != nil {
+		return nil, err
+	}
+	b, err := newCanonicalBlocks(pathB, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &traceDiff{}
+	entryA, okA, err := a.next()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", pathA, err)
+	}
+	entryB, okB, err := b.next()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", pathB, err)
+	}
+
+	// Compare the blocks up to the first divergence, and read the rest for the total supply
+	for okA || okB {
+		if diff.Divergence == nil {
+			diff.Divergence = compareBlocks(entryA, okA, entryB, okB)
+			if diff.Divergence == nil {
+				diff.Compared++
+			}
+		}
+
+		// Advance the side behind, or both when at the same block
+		advanceA := okA && (!okB || entryA.Number <= entryB.Number)
+		advanceB := okB && (!okA || entryB.Number <= entryA.Number)
+		if advanceA {
+			if entryA, okA, err = a.next(); err != nil {
+				return nil, fmt.Errorf("%s: %v", pathA, err)
+			}
+		}
+		if advanceB {
+			if entryB, okB, err = b.next(); err != nil {
+				return nil, fmt.Errorf("%s: %v", pathB, err)
+			}
+		}
+	}
+
+	diff.supplyDiff = compareSupply(a.state.Snapshot(), b.state.Snapshot())
+	diff.Equal = diff.Equal && diff.Divergence == nil
+
+	return diff, nil
+}
+
+// compareBlocks returns the differences of the canonical blocks of both sides, or nil if they are the same.
+// A block missing from one side is a divergence.
+func compareBlocks(a supply.Info, okA bool, b supply.Info, okB bool) *blockDivergence {
+	switch {
+	case !okB || (okA && a.Number < b.Number):
+		return &blockDivergence{BlockNumber: a.Number, Differences: []string{"only in a"}}
+	case !okA || b.Number < a.Number:
+		return &blockDivergence{BlockNumber: b.Number, Differences: []string{"only in b"}}
+	}
+
+	var differences []string
+	if a.Hash != b.Hash {
+		differences = append(differences, fmt.Sprintf("hash: %s != %s", a.Hash, b.Hash))
+	}
+	if a.ParentHash != b.ParentHash {
+		differences = append(differences, fmt.Sprintf("parentHash: %s != %s", a.ParentHash, b.ParentHash))
+	}
+	if a.Timestamp != 0 && b.Timestamp != 0 && a.Timestamp != b.Timestamp {
+		differences = append(differences, fmt.Sprintf("timestamp: %d != %d", a.Timestamp, b.Timestamp))
+	}
+
+	components := []componentDiff{newComponentDiff("delta", a.CalculatedDelta(), b.CalculatedDelta())}
+	components = append(components, compareCategories("issuance ", a.Issuance.Categories(), b.Issuance.Categories())...)
+	components = append(components, compareCategories("burn ", a.Burn.Categories(), b.Burn.Categories())...)
+	for _, c := range components {
+		if c.Difference.ToInt().Sign() != 0 {
+			differences = append(differences, fmt.Sprintf("%s: %s != %s", c.Name, c.A.ToInt(), c.B.ToInt()))
+		}
+	}
+
+	if len(differences) == 0 {
+		return nil
+	}
+
+	return &blockDivergence{BlockNumber: a.Number, Differences: differences}
+}
+
+// printSupplyDiff prints the components of both sides for humans, in wei
+func printSupplyDiff(out io.Writer, diff *supplyDiff) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "\ta\tb\tdifference\n")
+	fmt.Fprintf(w, "block\t%d %s\t%d %s\t\n", diff.A.Number, diff.A.Hash.TerminalString(), diff.B.Number, diff.B.Hash.TerminalString())
+	for _, c := range diff.Components {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Name, c.A.ToInt(), c.B.ToInt(), c.Difference.ToInt())
+	}
+	w.Flush()
+
+	if diff.Equal {
+		fmt.Fprintln(out, "\nThe total supplies are equal")
+	}
+}
+
+// printTraceDiff prints the first divergent block and the total supplies for humans
+func printTraceDiff(out io.Writer, diff *traceDiff) {
+	if diff.Divergence == nil {
+		fmt.Fprintf(out, "The %d canonical blocks are the same\n\n", diff.Compared)
+	} else {
+		fmt.Fprintf(out, "The canonical blocks diverge at block %d, after %d same blocks:\n", diff.Divergence.BlockNumber, diff.Compared)
+		for _, d := range diff.Divergence.Differences {
+			fmt.Fprintf(out, "  %s\n", d)
+		}
+		fmt.Fprintln(out)
+	}
+
+	printSupplyDiff(out, &diff.supplyDiff)
+}
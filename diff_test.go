@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+func TestCompareSupply(t *testing.T) {
+	a, b := tracker.NewState().Snapshot(), tracker.NewState().Snapshot()
+	a.Delta.SetInt64(10)
+	b.Delta.SetInt64(12)
+	a.Issuance.Reward.SetInt64(10)
+	b.Issuance.Reward.SetInt64(10)
+	b.Burn.Other = map[string]*big.Int{"systemContract": big.NewInt(-2)}
+
+	diff := compareSupply(a, b)
+	if diff.Equal {
+		t.Fatalf("want the supplies to differ")
+	}
+
+	differences := make(map[string]int64)
+	for _, c := range diff.Components {
+		differences[c.Name] = c.Difference.ToInt().Int64()
+	}
+	if len(differences) != 8 {
+		t.Errorf("want the delta and 7 components, have %v", differences)
+	}
+	if differences["delta"] != 2 || differences["issuance reward"] != 0 || differences["burn systemContract"] != -2 {
+		t.Errorf("unexpected differences %v", differences)
+	}
+
+	if diff := compareSupply(a, a); !diff.Equal {
+		t.Errorf("want a supply equal to itself")
+	}
+}
+
+func TestCompareTraces(t *testing.T) {
+	dir := t.TempDir()
+	pathA := filepath.Join(dir, "a", "supply.jsonl")
+	pathB := filepath.Join(dir, "b", "supply.jsonl")
+
+	// The canonical block 2 of b has a different reward
+	traceB := strings.Replace(exportTestSupply, `"issuance":{"reward":"0x3"}`, `"issuance":{"reward":"0x4"}`, 1)
+	for path, trace := range map[string]string{pathA: exportTestSupply, pathB: traceB} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(trace), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diff, err := compareTraces(pathA, pathB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Equal || diff.Divergence == nil || diff.Divergence.BlockNumber != 2 || diff.Compared != 2 {
+		t.Fatalf("want a divergence at block 2 after 2 blocks, have %+v", diff)
+	}
+	want := []string{"delta: 3 != 4", "issuance reward: 3 != 4"}
+	if strings.Join(diff.Divergence.Differences, ",") != strings.Join(want, ",") {
+		t.Errorf("want differences %v, have %v", want, diff.Divergence.Differences)
+	}
+	if diff.Components[0].Name != "delta" || diff.Components[0].Difference.ToInt().Int64() != 1 {
+		t.Errorf("want a total delta difference of 1, have %+v", diff.Components[0])
+	}
+
+	same, err := compareTraces(pathA, pathA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !same.Equal || same.Divergence != nil || same.Compared != 4 {
+		t.Errorf("want 4 same blocks, have %+v", same)
+	}
+}
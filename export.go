@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/urfave/cli/v2"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+var (
+	exportOutputFlag = &cli.StringFlag{
+		Name:  "output",
+		Usage: "File to write the export to, or \"-\" for stdout",
+		Value: "-",
+	}
+	exportFormatFlag = &cli.StringFlag{
+		Name:  "format",
+		Usage: "Format of the export, \"csv\" or \"parquet\". Defaults to the extension of the output file, or csv",
+	}
+	exportFromFlag = &cli.Uint64Flag{
+		Name:  "from",
+		Usage: "First block number to export",
+	}
+	exportToFlag = &cli.Uint64Flag{
+		Name:  "to",
+		Usage: "Last block number to export (default: the last block)",
+	}
+	exportColumnsFlag = &cli.StringFlag{
+		Name:  "columns",
+		Usage: "Comma separated columns to export (default: all). Available: " + strings.Join(exportColumnNames(), ","),
+	}
+
+	exportCommand = &cli.Command{
+		Name:  "export",
+		Usage: "Export the canonical supply data of the supply files to CSV or Parquet",
+		Description: `Reads the supply file and its log rotated files once, handling the reorgs as the tracker does,
+and writes a row per canonical block with its supply data and the cumulative totals up to it.
+The amounts are decimal wei. Unknown issuance and burn categories are only included in the deltas.`,
+		Flags: []cli.Flag{
+			supplyFileFlag,
+			timestampsFileFlag,
+			exportOutputFlag,
+			exportFormatFlag,
+			exportFromFlag,
+			exportToFlag,
+			exportColumnsFlag,
+		},
+		Action: export,
+	}
+)
+
+// exportRow is a canonical block and the cumulative totals up to it
+type exportRow struct {
+	entry supply.Info
+	total tracker.TotalSupply
+}
+
+// exportColumn is a column of the export
+type exportColumn struct {
+	name  string
+	value func(r *exportRow) interface{} // uint64 or a decimal string
+}
+
+// exportColumns are the available columns, in their order in CSV exports
+var exportColumns = []exportColumn{
+	{"blockNumber", func(r *exportRow) interface{} { return r.entry.Number }},
+	{"hash", func(r *exportRow) interface{} { return r.entry.Hash.Hex() }},
+	{"parentHash", func(r *exportRow) interface{} { return r.entry.ParentHash.Hex() }},
+	{"timestamp", func(r *exportRow) interface{} { return r.entry.Timestamp }},
+	{"delta", func(r *exportRow) interface{} { return r.entry.Delta.String() }},
+	{"genesisAlloc", func(r *exportRow) interface{} { return r.entry.Issuance.GenesisAlloc.String() }},
+	{"reward", func(r *exportRow) interface{} { return r.entry.Issuance.Reward.String() }},
+	{"withdrawals", func(r *exportRow) interface{} { return r.entry.Issuance.Withdrawals.String() }},
+	{"eip1559", func(r *exportRow) interface{} { return r.entry.Burn.EIP1559.String() }},
+	{"blob", func(r *exportRow) interface{} { return r.entry.Burn.Blob.String() }},
+	{"misc", func(r *exportRow) interface{} { return r.entry.Burn.Misc.String() }},
+	{"totalDelta", func(r *exportRow) interface{} { return r.total.Delta.String() }},
+	{"totalGenesisAlloc", func(r *exportRow) interface{} { return r.total.Issuance.GenesisAlloc.String() }},
+	{"totalReward", func(r *exportRow) interface{} { return r.total.Issuance.Reward.String() }},
+	{"totalWithdrawals", func(r *exportRow) interface{} { return r.total.Issuance.Withdrawals.String() }},
+	{"totalEip1559", func(r *exportRow) interface{} { return r.total.Burn.EIP1559.String() }},
+	{"totalBlob", func(r *exportRow) interface{} { return r.total.Burn.Blob.String() }},
+	{"totalMisc", func(r *exportRow) interface{} { return r.total.Burn.Misc.String() }},
+}
+
+func exportColumnNames() []string {
+	names := make([]string, 0, len(exportColumns))
+	for _, c := range exportColumns {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// selectColumns returns the columns of the comma separated names, or all of them when empty
+func selectColumns(names string) ([]exportColumn, error) {
+	if names == "" {
+		return exportColumns, nil
+	}
+
+	var columns []exportColumn
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if selected[name] {
+			return nil, fmt.Errorf("column %q is selected twice", name)
+		}
+		selected[name] = true
+
+		found := false
+		for _, c := range exportColumns {
+			if c.name == name {
+				columns = append(columns, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+	}
+
+	return columns, nil
+}
+
+// rowWriter writes the rows of an export
+type rowWriter interface {
+	Write(values []interface{}) error
+	Close() error
+}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func newCSVRowWriter(out io.Writer, columns []exportColumn) (*csvRowWriter, error) {
+	w := csv.NewWriter(out)
+
+	header := make([]string, 0, len(columns))
+	for _, c := range columns {
+		header = append(header, c.name)
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &csvRowWriter{w: w}, nil
+}
+
+func (c *csvRowWriter) Write(values []interface{}) error {
+	record := make([]string, 0, len(values))
+	for _, v := range values {
+		record = append(record, fmt.Sprint(v))
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvRowWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type parquetRowWriter struct {
+	w *parquet.Writer
+
+	// index maps the selected columns to the columns of the schema, which are sorted by name
+	index []int
+}
+
+func newParquetRowWriter(out io.Writer, columns []exportColumn) *parquetRowWriter {
+	group := make(parquet.Group, len(columns))
+	for _, c := range columns {
+		if _, ok := c.value(&exportRow{entry: supply.New(), total: tracker.NewState().TotalSupply}).(uint64); ok {
+			group[c.name] = parquet.Uint(64)
+		} else {
+			group[c.name] = parquet.String()
+		}
+	}
+	schema := parquet.NewSchema("supply", group)
+
+	names := make([]string, 0, len(columns))
+	for _, c := range columns {
+		names = append(names, c.name)
+	}
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	index := make([]int, len(names))
+	for i, name := range names {
+		index[i] = sort.SearchStrings(sorted, name)
+	}
+
+	return &parquetRowWriter{w: parquet.NewWriter(out, schema), index: index}
+}
+
+func (p *parquetRowWriter) Write(values []interface{}) error {
+	row := make(parquet.Row, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			v = []byte(s)
+		}
+		row[p.index[i]] = parquet.ValueOf(v).Level(0, 0, p.index[i])
+	}
+	_, err := p.w.WriteRows([]parquet.Row{row})
+	return err
+}
+
+func (p *parquetRowWriter) Close() error {
+	return p.w.Close()
+}
+
+func export(ctx *cli.Context) error {
+	columns, err := selectColumns(ctx.String(exportColumnsFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	from := ctx.Uint64(exportFromFlag.Name)
+	to := ctx.Uint64(exportToFlag.Name)
+	if !ctx.IsSet(exportToFlag.Name) {
+		to = ^uint64(0)
+	}
+	if from > to {
+		return fmt.Errorf("--%s %d is after --%s %d", exportFromFlag.Name, from, exportToFlag.Name, to)
+	}
+
+	timestamps, err := loadTimestamps(ctx)
+	if err != nil {
+		return err
+	}
+
+	output := ctx.String(exportOutputFlag.Name)
+	format := ctx.String(exportFormatFlag.Name)
+	if format == "" {
+		format = "csv"
+		if strings.EqualFold(filepath.Ext(output), ".parquet") {
+			format = "parquet"
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if output != "-" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %v", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	var w rowWriter
+	switch format {
+	case "csv":
+		if w, err = newCSVRowWriter(out, columns); err != nil {
+			return fmt.Errorf("failed to write the export: %v", err)
+		}
+	case "parquet":
+		w = newParquetRowWriter(out, columns)
+	default:
+		return fmt.Errorf("unknown format %q, want csv or parquet", format)
+	}
+
+	exporter := &exporter{
+		columns: columns,
+		from:    from,
+		to:      to,
+		w:       w,
+	}
+	if err := exporter.run(ctx.String(supplyFileFlag.Name), timestamps); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write the export: %v", err)
+	}
+
+	return nil
+}
+
+// exporter writes the canonical blocks of the supply files, once they can no longer be reorged
+type exporter struct {
+	columns []exportColumn
+	from    uint64
+	to      uint64
+	w       rowWriter
+}
+
+func (e *exporter) run(path string, timestamps map[uint64]uint64) error {
+	blocks, err := newCanonicalBlocks(path, timestamps)
+	if err != nil {
+		return err
+	}
+
+	// The totals up to the written blocks are accounted by a state of the canonical blocks only
+	total := tracker.NewState()
+	total.SetHistoryLimit(1)
+	entryErrCh := make(chan error, 16)
+
+	for {
+		entry, ok, err := blocks.next()
+		if err != nil {
+			return err
+		}
+		if !ok || entry.Number > e.to {
+			return nil
+		}
+
+		total.HandleEntry(entry, entryErrCh)
+		if len(entryErrCh) > 0 {
+			return fmt.Errorf("failed to total block %d: %v", entry.Number, <-entryErrCh)
+		}
+
+		if entry.Number < e.from {
+			continue
+		}
+
+		row := exportRow{entry: entry, total: total.Snapshot()}
+		values := make([]interface{}, 0, len(e.columns))
+		for _, c := range e.columns {
+			values = append(values, c.value(&row))
+		}
+		if err := e.w.Write(values); err != nil {
+			return fmt.Errorf("failed to write block %d: %v", entry.Number, err)
+		}
+	}
+}
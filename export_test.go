@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// exportTestSupply has a reorg of block 2
+const exportTestSupply = `{"blockNumber":0,"hash":"0x0100000000000000000000000000000000000000000000000000000000000000","parentHash":"0x0000000000000000000000000000000000000000000000000000000000000000","issuance":{"genesisAlloc":"0x10"}}
+{"blockNumber":1,"hash":"0x0200000000000000000000000000000000000000000000000000000000000000","parentHash":"0x0100000000000000000000000000000000000000000000000000000000000000","issuance":{"reward":"0x2"}}
+{"blockNumber":2,"hash":"0x0300000000000000000000000000000000000000000000000000000000000000","parentHash":"0x0200000000000000000000000000000000000000000000000000000000000000","issuance":{"reward":"0x2"},"burn":{"eip1559":"0x1"}}
+{"blockNumber":2,"hash":"0x0400000000000000000000000000000000000000000000000000000000000000","parentHash":"0x0200000000000000000000000000000000000000000000000000000000000000","issuance":{"reward":"0x3"}}
+{"blockNumber":3,"hash":"0x0500000000000000000000000000000000000000000000000000000000000000","parentHash":"0x0400000000000000000000000000000000000000000000000000000000000000","issuance":{"reward":"0x2"}}
+`
+
+func runExport(t *testing.T, w func(columns []exportColumn) rowWriter, columns string, from, to uint64) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "supply.jsonl")
+	if err := os.WriteFile(path, []byte(exportTestSupply), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	selected, err := selectColumns(columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw := w(selected)
+	e := &exporter{columns: selected, from: from, to: to, w: rw}
+	if err := e.run(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	var out bytes.Buffer
+	runExport(t, func(columns []exportColumn) rowWriter {
+		w, err := newCSVRowWriter(&out, columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}, "blockNumber,reward,totalDelta", 1, 2)
+
+	// Only the canonical block 2 is exported, with the totals of the blocks before the range
+	want := "blockNumber,reward,totalDelta\n1,2,18\n2,3,21\n"
+	if out.String() != want {
+		t.Errorf("want export\n%s\nhave\n%s", want, out.String())
+	}
+}
+
+func TestExportParquet(t *testing.T) {
+	var out bytes.Buffer
+	runExport(t, func(columns []exportColumn) rowWriter {
+		return newParquetRowWriter(&out, columns)
+	}, "totalDelta,blockNumber", 0, ^uint64(0))
+
+	r := parquet.NewReader(bytes.NewReader(out.Bytes()))
+	defer r.Close()
+	if r.NumRows() != 4 {
+		t.Fatalf("want 4 rows, have %d", r.NumRows())
+	}
+
+	// The columns of the schema are sorted by name
+	rows := make([]parquet.Row, 4)
+	if n, err := r.ReadRows(rows); n != 4 {
+		t.Fatalf("read %d rows: %v", n, err)
+	}
+	if number, total := rows[3][0].Uint64(), string(rows[3][1].ByteArray()); number != 3 || total != "23" {
+		t.Errorf("unexpected last row: number %d, total delta %s", number, total)
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	for _, columns := range []string{"blockNumber,unknown", "hash,hash"} {
+		if _, err := selectColumns(columns); err == nil || !strings.Contains(err.Error(), "column") {
+			t.Errorf("want error for columns %q, have %v", columns, err)
+		}
+	}
+}
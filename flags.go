@@ -0,0 +1,165 @@
+package main
+
+import (
+	"github.com/urfave/cli/v2"
+	"github.com/ziogaschr/supply-tracer-parser/api"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+var (
+	supplyFileFlag = &cli.StringFlag{
+		Name:  "supply.file",
+		Value: "supply.jsonl",
+		Usage: "File to read supply data from. Supports reading log rotated files.",
+	}
+	supplyURLFlag = &cli.StringFlag{
+		Name:  "supply.url",
+		Usage: "Stream to read supply data from instead of files (unix://, tcp://, http(s):// or file:// for named pipes)",
+	}
+	stateFileFlag = &cli.StringFlag{
+		Name:  "state.file",
+		Value: "state.json",
+		Usage: "File to store latest state for subsequent runs",
+	}
+	apiPortFlag = &cli.IntFlag{
+		Name:  "api.port",
+		Usage: "API port to expose the latest state",
+		Value: 8080,
+	}
+	apiAddrFlag = &cli.StringFlag{
+		Name:  "api.addr",
+		Usage: "host:port to expose the API on, e.g. \"127.0.0.1:8080\". Overrides --api.port",
+	}
+	apiSocketFlag = &cli.StringFlag{
+		Name:  "api.socket",
+		Usage: "Unix domain socket to expose the API on. Only the socket is served, unless --api.addr or --api.port is set",
+	}
+	apiTLSCertFlag = &cli.StringFlag{
+		Name:  "api.tls.cert",
+		Usage: "TLS certificate file to serve the API over HTTPS, along with --api.tls.key",
+	}
+	apiTLSKeyFlag = &cli.StringFlag{
+		Name:  "api.tls.key",
+		Usage: "TLS key file to serve the API over HTTPS, along with --api.tls.cert",
+	}
+	apiReadTimeoutFlag = &cli.DurationFlag{
+		Name:  "api.timeout.read",
+		Usage: "Maximum duration of reading an API request",
+		Value: api.DefaultReadTimeout,
+	}
+	apiWriteTimeoutFlag = &cli.DurationFlag{
+		Name:  "api.timeout.write",
+		Usage: "Maximum duration of writing an API response",
+		Value: api.DefaultWriteTimeout,
+	}
+	apiIdleTimeoutFlag = &cli.DurationFlag{
+		Name:  "api.timeout.idle",
+		Usage: "Maximum duration to keep idle API connections open",
+		Value: api.DefaultIdleTimeout,
+	}
+	apiAuthTokenFlag = &cli.StringSliceFlag{
+		Name:  "api.auth.token",
+		Usage: "Bearer token to authenticate the API requests with. Can be set multiple times.",
+	}
+	apiAuthSecretFlag = &cli.StringFlag{
+		Name:  "api.auth.secret",
+		Usage: "Secret to authenticate the API requests signed with HMAC-SHA256",
+	}
+	apiAuthPublicFlag = &cli.StringSliceFlag{
+		Name:  "api.auth.public",
+		Usage: "Path of the API served without authentication, e.g. \"/\". Can be set multiple times.",
+	}
+	apiRateLimitFlag = &cli.Float64Flag{
+		Name:  "api.ratelimit.rate",
+		Usage: "Requests per second allowed to each API client (0 = disabled)",
+	}
+	apiRateLimitBurstFlag = &cli.IntFlag{
+		Name:  "api.ratelimit.burst",
+		Usage: "Requests each API client can make at once, over the rate",
+		Value: 20,
+	}
+	apiRateLimitHeaderFlag = &cli.StringFlag{
+		Name:  "api.ratelimit.header",
+		Usage: "Header identifying the API clients behind a proxy, e.g. \"X-Forwarded-For\", instead of their address",
+	}
+	apiCORSOriginsFlag = &cli.StringSliceFlag{
+		Name:  "api.cors.origin",
+		Usage: "Origin allowed to make cross-origin API requests from browsers, \"*\" for any. Can be set multiple times.",
+	}
+	validationPolicyFlag = &cli.StringFlag{
+		Name:  "validation.policy",
+		Usage: "Policy for entries with error severity validation violations: \"log\" only reports them, \"fail\" rejects them and exits",
+		Value: "log",
+	}
+	webhookURLFlag = &cli.StringSliceFlag{
+		Name:  "webhook.url",
+		Usage: "URL to send webhook notifications to. Can be set multiple times.",
+	}
+	webhookSecretFlag = &cli.StringFlag{
+		Name:  "webhook.secret",
+		Usage: "Secret to sign the webhook payloads with HMAC-SHA256",
+	}
+	webhookEveryFlag = &cli.Uint64Flag{
+		Name:  "webhook.every",
+		Usage: "Send a webhook notification every N blocks (0 = disabled)",
+	}
+	webhookReorgDepthFlag = &cli.IntFlag{
+		Name:  "webhook.reorgdepth",
+		Usage: "Send a webhook notification for reorgs deeper than this",
+		Value: 3,
+	}
+	webhookStallFlag = &cli.DurationFlag{
+		Name:  "webhook.stall",
+		Usage: "Send a webhook notification when no new blocks are read for this long (0 = disabled)",
+	}
+	webhookRetriesFlag = &cli.IntFlag{
+		Name:  "webhook.retries",
+		Usage: "Number of retries of failed webhook deliveries",
+		Value: 5,
+	}
+	seedFileFlag = &cli.StringFlag{
+		Name:  "seed.file",
+		Usage: "JSON file with the starting block of the state, when tracing started mid-chain: {\"blockNumber\": ..., \"hash\": ..., \"totalSupply\": ..., \"issuance\": {...}, \"burn\": {...}}",
+	}
+	seedNumberFlag = &cli.Uint64Flag{
+		Name:  "seed.number",
+		Usage: "Block number of the starting block of the state, overrides the seed file",
+	}
+	seedHashFlag = &cli.StringFlag{
+		Name:  "seed.hash",
+		Usage: "Block hash of the starting block of the state, overrides the seed file",
+	}
+	seedSupplyFlag = &cli.StringFlag{
+		Name:  "seed.supply",
+		Usage: "Absolute total supply at the starting block of the state in wei (decimal or hex), overrides the seed file",
+	}
+	reconcileAnchorAPIFlag = &cli.BoolFlag{
+		Name:  "reconcile.anchor",
+		Usage: "Allow anchoring the total supply through the /reconcile endpoint of the API, by authenticated requests",
+	}
+	erasNetworkFlag = &cli.StringFlag{
+		Name:  "eras.network",
+		Usage: "Network of the fork boundaries to break down the totals by era (mainnet, sepolia)",
+	}
+	erasFlag = &cli.StringFlag{
+		Name:  "eras",
+		Usage: "Custom fork boundaries to break down the totals by era, as name=block pairs, e.g. \"paris=15537394,shanghai=17034870\". Overrides --eras.network",
+	}
+	timestampsFileFlag = &cli.StringFlag{
+		Name:  "timestamps.file",
+		Usage: "Sidecar file of block timestamps, as number,timestamp lines, for traces without them",
+	}
+	historyLimitFlag = &cli.IntFlag{
+		Name:  "history.limit",
+		Usage: "Number of recent blocks to keep in history, the deepest reorg that can be handled",
+		Value: tracker.DefaultHistoryLimit,
+	}
+	configFileFlag = &cli.StringFlag{
+		Name:  "config",
+		Usage: "YAML or TOML file with the options by their flag names, e.g. \"supply.file: supply.jsonl\". Flags and environment variables override it",
+	}
+	freshFlag = &cli.BoolFlag{
+		Name:  "fresh",
+		Usage: "nuke the state and start fresh",
+	}
+)
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/urfave/cli/v2"
+	"github.com/ziogaschr/supply-tracer-parser/reader"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+var (
+	inspectJSONFlag = &cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the records as JSON",
+	}
+
+	inspectCommand = &cli.Command{
+		Name:      "inspect",
+		Usage:     "Explain the accounting of a block of the supply files",
+		ArgsUsage: "<number|hash>",
+		Description: `Reads the supply file and its log rotated files once, handling the reorgs as the tracker does,
+and prints every record of the block number, side forks included: its issuance and burn components,
+the reported and calculated delta, its parent and children, and whether it ended up canonical.
+Given a hash, the records of the number of that block are printed.`,
+		Flags: []cli.Flag{
+			supplyFileFlag,
+			timestampsFileFlag,
+			inspectJSONFlag,
+		},
+		Action: inspect,
+	}
+)
+
+// inspectedRecord is a record of the inspected block in the supply files
+type inspectedRecord struct {
+	Pos        string      `json:"pos"`
+	Number     uint64      `json:"blockNumber"`
+	Hash       common.Hash `json:"hash"`
+	ParentHash common.Hash `json:"parentHash"`
+	Timestamp  uint64      `json:"timestamp,omitempty"`
+
+	Issuance        []inspectedComponent `json:"issuance"`
+	Burn            []inspectedComponent `json:"burn"`
+	ReportedDelta   *hexutil.Big         `json:"reportedDelta"` // Nil when the tracer did not write it
+	CalculatedDelta *hexutil.Big         `json:"calculatedDelta"`
+
+	Canonical       bool          `json:"canonical"`
+	ParentFound     bool          `json:"parentFound"` // Whether the parent is in the supply files
+	ParentCanonical bool          `json:"parentCanonical"`
+	Children        []common.Hash `json:"children"`
+	Rejected        string        `json:"rejected,omitempty"` // Why the state skipped the record
+}
+
+type inspectedComponent struct {
+	Name   string       `json:"name"`
+	Amount *hexutil.Big `json:"amount"`
+	Known  bool         `json:"known"`
+}
+
+func inspectedComponents(categories []supply.Category) []inspectedComponent {
+	components := make([]inspectedComponent, 0, len(categories))
+	for _, c := range categories {
+		components = append(components, inspectedComponent{Name: c.Name, Amount: (*hexutil.Big)(c.Amount), Known: c.Known})
+	}
+	return components
+}
+
+func inspect(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return errors.New("expected a block number or hash")
+	}
+
+	timestamps, err := loadTimestamps(ctx)
+	if err != nil {
+		return err
+	}
+
+	records, err := inspectBlock(ctx.String(supplyFileFlag.Name), ctx.Args().First(), timestamps)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool(inspectJSONFlag.Name) {
+		out, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal records: %v", err)
+		}
+		fmt.Fprintln(os.Stdout, string(out))
+		return nil
+	}
+
+	return printRecords(os.Stdout, records)
+}
+
+// parseBlockQuery parses a block hash, or a decimal or hex block number
+func parseBlockQuery(query string) (uint64, common.Hash, error) {
+	if strings.HasPrefix(query, "0x") && len(query) == 2+2*common.HashLength {
+		hash := common.HexToHash(query)
+		if hash.Hex() != strings.ToLower(query) {
+			return 0, common.Hash{}, fmt.Errorf("invalid block hash %q", query)
+		}
+		return 0, hash, nil
+	}
+
+	number, err := strconv.ParseUint(query, 0, 64)
+	if err != nil {
+		return 0, common.Hash{}, fmt.Errorf("invalid block number or hash %q", query)
+	}
+
+	return number, common.Hash{}, nil
+}
+
+// inspectBlock returns the records of the block number, or of the number of the block hash, in the supply files.
+// The timestamps are used for the records without one.
+func inspectBlock(path, query string, timestamps map[uint64]uint64) ([]inspectedRecord, error) {
+	number, hash, err := parseBlockQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if hash != (common.Hash{}) {
+		if number, err = findBlockNumber(path, hash); err != nil {
+			return nil, err
+		}
+	}
+
+	eventsCh, err := reader.ReadFiles(path, "", true)
+	if err != nil {
+		return nil, err
+	}
+
+	state := tracker.NewState()
+	var records []inspectedRecord
+	parents := make(map[common.Hash]bool)           // Blocks of the previous number
+	children := make(map[common.Hash][]common.Hash) // Blocks of the next number, by their parent
+
+	entryErrCh := make(chan error, 16)
+	for event := range eventsCh {
+		switch event.Kind {
+		case reader.EventBlock:
+			entry := event.Supply
+			fillTimestamp(&entry, timestamps)
+
+			state.HandleEntry(entry, entryErrCh)
+			var rejected []string
+			for len(entryErrCh) > 0 {
+				rejected = append(rejected, (<-entryErrCh).Error())
+			}
+
+			switch {
+			case entry.Number == number:
+				records = append(records, inspectedRecord{
+					Pos:             event.Pos.String(),
+					Number:          entry.Number,
+					Hash:            entry.Hash,
+					ParentHash:      entry.ParentHash,
+					Timestamp:       entry.Timestamp,
+					Issuance:        inspectedComponents(entry.Issuance.Categories()),
+					Burn:            inspectedComponents(entry.Burn.Categories()),
+					ReportedDelta:   (*hexutil.Big)(entry.ReportedDelta),
+					CalculatedDelta: (*hexutil.Big)(entry.CalculatedDelta()),
+					Rejected:        strings.Join(rejected, "; "),
+				})
+			case number > 0 && entry.Number == number-1:
+				parents[entry.Hash] = true
+			case entry.Number == number+1:
+				children[entry.ParentHash] = append(children[entry.ParentHash], entry.Hash)
+			}
+		case reader.EventError:
+			return nil, fmt.Errorf("%v\n\tat %s", event.Err, event.Pos)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("block %d is not in the supply files", number)
+	}
+
+	canonical, _ := state.CanonicalHash(number)
+	var parentCanonical common.Hash
+	if number > 0 {
+		parentCanonical, _ = state.CanonicalHash(number - 1)
+	}
+	for i := range records {
+		r := &records[i]
+		r.Canonical = r.Hash == canonical
+		r.ParentFound = parents[r.ParentHash]
+		r.ParentCanonical = number > 0 && r.ParentHash == parentCanonical
+		r.Children = children[r.Hash]
+	}
+
+	return records, nil
+}
+
+// findBlockNumber returns the number of the block hash in the supply files
+func findBlockNumber(path string, hash common.Hash) (uint64, error) {
+	eventsCh, err := reader.ReadFiles(path, "", true)
+	if err != nil {
+		return 0, err
+	}
+
+	var number uint64
+	found := false
+	for event := range eventsCh {
+		switch event.Kind {
+		case reader.EventBlock:
+			if !found && event.Supply.Hash == hash {
+				number = event.Supply.Number
+				found = true
+			}
+		case reader.EventError:
+			return 0, fmt.Errorf("%v\n\tat %s", event.Err, event.Pos)
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("block %s is not in the supply files", hash)
+	}
+
+	return number, nil
+}
+
+// printRecords prints the records for humans, with the amounts in wei
+func printRecords(out io.Writer, records []inspectedRecord) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	for i, r := range records {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Block %d %s\n", r.Number, r.Hash)
+		fmt.Fprintf(w, "  at\t%s\n", r.Pos)
+		fmt.Fprintf(w, "  canonical\t%s\n", yesNo(r.Canonical))
+		if r.Timestamp != 0 {
+			fmt.Fprintf(w, "  timestamp\t%d\n", r.Timestamp)
+		}
+
+		parent := "not in the supply files"
+		if r.ParentFound {
+			parent = "in the supply files, not canonical"
+			if r.ParentCanonical {
+				parent = "in the supply files, canonical"
+			}
+		}
+		fmt.Fprintf(w, "  parent\t%s (%s)\n", r.ParentHash, parent)
+		if len(r.Children) == 0 {
+			fmt.Fprintf(w, "  children\tnone\n")
+		}
+		for _, child := range r.Children {
+			fmt.Fprintf(w, "  child\t%s\n", child)
+		}
+
+		for _, c := range r.Issuance {
+			fmt.Fprintf(w, "  issuance %s\t%s%s\n", c.Name, wei(c.Amount), unknownSuffix(c.Known))
+		}
+		for _, c := range r.Burn {
+			fmt.Fprintf(w, "  burn %s\t%s%s\n", c.Name, wei(c.Amount), unknownSuffix(c.Known))
+		}
+
+		fmt.Fprintf(w, "  calculated delta\t%s\n", wei(r.CalculatedDelta))
+		switch {
+		case r.ReportedDelta == nil:
+			fmt.Fprintf(w, "  reported delta\tnot written\n")
+		case r.ReportedDelta.ToInt().Cmp(r.CalculatedDelta.ToInt()) == 0:
+			fmt.Fprintf(w, "  reported delta\t%s (matches)\n", wei(r.ReportedDelta))
+		default:
+			fmt.Fprintf(w, "  reported delta\t%s (MISMATCH)\n", wei(r.ReportedDelta))
+		}
+
+		if r.Rejected != "" {
+			fmt.Fprintf(w, "  rejected\t%s\n", strings.ReplaceAll(r.Rejected, "\n", " "))
+		}
+	}
+
+	return w.Flush()
+}
+
+// wei formats a hex big integer as decimal wei
+func wei(n *hexutil.Big) string {
+	if n == nil {
+		return "0"
+	}
+	return n.ToInt().String()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func unknownSuffix(known bool) string {
+	if known {
+		return ""
+	}
+	return " (unknown category)"
+}
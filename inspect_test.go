@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestInspectBlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "supply.jsonl")
+	if err := os.WriteFile(path, []byte(exportTestSupply), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// By hash, the side fork and the canonical block of the number are inspected
+	records, err := inspectBlock(path, "0x0300000000000000000000000000000000000000000000000000000000000000", map[uint64]uint64{2: 1681338455})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("want 2 records of block 2, have %d", len(records))
+	}
+
+	side, canonical := records[0], records[1]
+	if side.Hash != (common.Hash{3}) || side.Canonical || canonical.Hash != (common.Hash{4}) || !canonical.Canonical {
+		t.Errorf("want the side fork 0x03 and the canonical 0x04, have %s %v and %s %v", side.Hash, side.Canonical, canonical.Hash, canonical.Canonical)
+	}
+	if !side.ParentFound || !side.ParentCanonical || !canonical.ParentFound || !canonical.ParentCanonical {
+		t.Errorf("want the canonical parent of both records found")
+	}
+	if len(side.Children) != 0 || len(canonical.Children) != 1 || canonical.Children[0] != (common.Hash{5}) {
+		t.Errorf("want the child 0x05 of the canonical block only, have %v and %v", side.Children, canonical.Children)
+	}
+	if side.Timestamp != 1681338455 || canonical.Timestamp != 1681338455 {
+		t.Errorf("want the timestamps of the timestamps file, have %d and %d", side.Timestamp, canonical.Timestamp)
+	}
+	if side.CalculatedDelta.ToInt().Int64() != 1 || side.ReportedDelta != nil {
+		t.Errorf("want calculated delta 1 and no reported delta, have %s %v", side.CalculatedDelta, side.ReportedDelta)
+	}
+
+	var out bytes.Buffer
+	if err := printRecords(&out, records); err != nil {
+		t.Fatal(err)
+	}
+	printed := strings.Join(strings.Fields(out.String()), " ")
+	for _, want := range []string{"canonical no", "canonical yes", "timestamp 1681338455", "burn eip1559 1", "reported delta not written"} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("want %q in the output\n%s", want, out.String())
+		}
+	}
+
+	if _, err := inspectBlock(path, "9", nil); err == nil {
+		t.Errorf("want an error for a missing block")
+	}
+	if _, err := inspectBlock(path, "two", nil); err == nil {
+		t.Errorf("want an error for an invalid query")
+	}
+}
@@ -1,15 +1,28 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/urfave/cli/v2"
+	"github.com/ziogaschr/supply-tracer-parser/api"
+	"github.com/ziogaschr/supply-tracer-parser/reader"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+	"github.com/ziogaschr/supply-tracer-parser/validate"
+	"github.com/ziogaschr/supply-tracer-parser/webhook"
 )
 
+// shutdownTimeout is the time to wait for the state to be saved on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func run(ctx *cli.Context) error {
 	supplyFilePath := ctx.String("supply.file")
 	stateFilePath := ctx.String("state.file")
@@ -20,29 +33,131 @@ func run(ctx *cli.Context) error {
 		os.Remove(stateFilePath)
 	}
 
-	state := NewState()
+	state := tracker.NewState()
 
 	// Load state from file if it exists
 	lastParsedFile, err := state.LoadState(stateFilePath)
+	if errors.Is(err, tracker.ErrNewerSchema) {
+		// Refuse to overwrite a state file written by a newer version
+		log.Fatal(err)
+	}
 	if err != nil {
 		log.Println(err)
+
+		// Start from the seed block, when tracing started mid-chain
+		seed, err := loadSeed(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if seed != nil {
+			state, err = tracker.NewSeededState(*seed)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Seeded state at block %d (%s) with total supply %s", seed.BlockNumber, seed.Hash, seed.TotalSupply)
+		}
+	} else if ctx.IsSet(seedFileFlag.Name) || ctx.IsSet(seedNumberFlag.Name) {
+		log.Println("Ignoring the seed, as the state was loaded from the state file")
+	}
+
+	// Keep enough history for the deepest expected reorg
+	limit := ctx.Int(historyLimitFlag.Name)
+	if limit < 1 {
+		log.Fatalf("--%s has to be positive, have %d", historyLimitFlag.Name, limit)
+	}
+	state.SetHistoryLimit(limit)
+
+	// Break down the totals by era
+	eras, err := loadEras(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if eras != nil {
+		state.SetEras(eras)
+	}
+
+	// Timestamps of the blocks, for traces without them
+	timestamps, err := loadTimestamps(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tracker.PublishRates(state)
+
+	// Validate the supply entries before handling them
+	validator := validate.New()
+	failOnViolation, err := validationPolicy(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Send webhook notifications for the state events
+	var notifier *webhook.Notifier
+	if urls := ctx.StringSlice("webhook.url"); len(urls) > 0 {
+		notifier = webhook.New(webhook.Config{
+			URLs:         urls,
+			Secret:       ctx.String("webhook.secret"),
+			EveryBlocks:  ctx.Uint64("webhook.every"),
+			ReorgDepth:   ctx.Int("webhook.reorgdepth"),
+			StallTimeout: ctx.Duration("webhook.stall"),
+			MaxRetries:   ctx.Int("webhook.retries"),
+		})
+		notifier.Start()
+		state.Subscribe(notifier.Hooks())
 	}
 
 	// Handle fatal errors from goroutines that will exit the program
 	errCh := make(chan error, 16)
 	defer close(errCh)
 
-	linesCh, err := readFileStream(supplyFilePath, lastParsedFile, errCh)
+	var source reader.Source = reader.NewFileSource(supplyFilePath, lastParsedFile)
+	var stream *reader.StreamSource
+	if supplyURL := ctx.String("supply.url"); supplyURL != "" {
+		stream, err = reader.NewStreamSource(supplyURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		source = stream
+	}
+
+	eventsCh, err := source.Start()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// Closed once all the events have been handled
+	handled := make(chan struct{})
+
 	go func() {
-		for line := range linesCh {
-			if supply, ok := line.(supplyInfo); ok {
-				state.handleEntry(supply, errCh)
-			} else if lastParsedFilename, ok := line.(SaveLastParsedFile); ok {
-				state.SaveState(stateFilePath, string(lastParsedFilename))
+		defer close(handled)
+
+		// Errors of handleEntry are reported along with the position of the entry
+		entryErrCh := make(chan error, 16)
+
+		for event := range eventsCh {
+			switch event.Kind {
+			case reader.EventBlock:
+				fillTimestamp(&event.Supply, timestamps)
+				if violations := validator.Check(event.Supply, event.Pos); failOnViolation && hasError(violations) {
+					errCh <- fmt.Errorf("rejecting block %d entry, it failed validation\n\tat %s", event.Supply.Number, event.Pos)
+					continue
+				}
+
+				state.HandleEntry(event.Supply, entryErrCh)
+				for len(entryErrCh) > 0 {
+					errCh <- fmt.Errorf("%v\n\tat %s", <-entryErrCh, event.Pos)
+				}
+			case reader.EventCheckpoint:
+				// Streams have no files to resume after, the last parsed file of the state is kept
+				if stream != nil {
+					state.SaveState(stateFilePath, lastParsedFile)
+					continue
+				}
+				state.SaveState(stateFilePath, filepath.Base(event.Pos.File))
+			case reader.EventRotation:
+				log.Printf("Supply file rotated to %s", event.Pos.File)
+			case reader.EventError:
+				errCh <- fmt.Errorf("%v\n\tat %s", event.Err, event.Pos)
 			}
 		}
 	}()
@@ -54,51 +169,239 @@ func run(ctx *cli.Context) error {
 		for {
 			select {
 			case err := <-errCh:
+				// Deliver the pending notifications, as they may report the error
+				if notifier != nil {
+					notifier.Close()
+				}
 				log.Fatal("Exiting due to error: ", err)
 				os.Exit(1)
 			case sig := <-sigs:
 				log.Printf("Received signal \"%v\", exiting...", sig)
+
+				// Save the state of the blocks read from the stream since the last checkpoint
+				if stream != nil {
+					stream.Close()
+					select {
+					case <-handled:
+					case <-time.After(shutdownTimeout):
+						log.Println("Timed out saving the state")
+					}
+				}
 				os.Exit(0)
 			}
 		}
 	}()
 
-	if err := startAPI(ctx.Int("api.port"), state); err == nil {
-		return fmt.Errorf("failed to start the API: %s", err)
+	apiOpts := []api.Option{api.WithValidator(validator), api.WithReconcile(ctx.Bool("reconcile.anchor"))}
+	apiOpts = append(apiOpts, apiAccessOptions(ctx)...)
+	server, err := api.NewServer(apiServerConfig(ctx), state, apiOpts...)
+	if err != nil {
+		return fmt.Errorf("invalid API options: %v", err)
+	}
+	if err := server.ListenAndServe(); err != nil {
+		return fmt.Errorf("failed to start the API: %v", err)
 	}
 
 	return nil
 }
 
-func main() {
+// apiServerConfig returns the listeners and the timeouts of the API server from the flags.
+// The API is served on --api.port of all interfaces, unless --api.addr or only --api.socket is set.
+func apiServerConfig(ctx *cli.Context) api.ServerConfig {
+	addr := ctx.String(apiAddrFlag.Name)
+	if addr == "" && (ctx.IsSet(apiPortFlag.Name) || ctx.String(apiSocketFlag.Name) == "") {
+		addr = fmt.Sprintf(":%d", ctx.Int(apiPortFlag.Name))
+	}
+
+	return api.ServerConfig{
+		Addr:         addr,
+		Socket:       ctx.String(apiSocketFlag.Name),
+		CertFile:     ctx.String(apiTLSCertFlag.Name),
+		KeyFile:      ctx.String(apiTLSKeyFlag.Name),
+		ReadTimeout:  ctx.Duration(apiReadTimeoutFlag.Name),
+		WriteTimeout: ctx.Duration(apiWriteTimeoutFlag.Name),
+		IdleTimeout:  ctx.Duration(apiIdleTimeoutFlag.Name),
+	}
+}
+
+// apiAccessOptions returns the authentication, rate limiting and CORS options of the API from the flags
+func apiAccessOptions(ctx *cli.Context) []api.Option {
+	var opts []api.Option
+
+	tokens, secret := ctx.StringSlice(apiAuthTokenFlag.Name), ctx.String(apiAuthSecretFlag.Name)
+	if len(tokens) > 0 || secret != "" {
+		opts = append(opts, api.WithAuth(api.Auth{
+			Tokens: tokens,
+			Secret: secret,
+			Public: ctx.StringSlice(apiAuthPublicFlag.Name),
+		}))
+	}
+
+	if rate := ctx.Float64(apiRateLimitFlag.Name); rate > 0 {
+		if ctx.String(apiSocketFlag.Name) != "" && ctx.String(apiRateLimitHeaderFlag.Name) == "" {
+			log.Printf("The clients of the unix socket share a single rate limit, set --%s to tell them apart", apiRateLimitHeaderFlag.Name)
+		}
+		opts = append(opts, api.WithRateLimit(api.RateLimit{
+			Rate:         rate,
+			Burst:        ctx.Int(apiRateLimitBurstFlag.Name),
+			ClientHeader: ctx.String(apiRateLimitHeaderFlag.Name),
+		}))
+	}
+
+	if origins := ctx.StringSlice(apiCORSOriginsFlag.Name); len(origins) > 0 {
+		opts = append(opts, api.WithCORS(origins))
+	}
+
+	return opts
+}
+
+// loadTimestamps returns the block timestamps of the timestamps file, nil when it is not set
+func loadTimestamps(ctx *cli.Context) (map[uint64]uint64, error) {
+	path := ctx.String(timestampsFileFlag.Name)
+	if path == "" {
+		return nil, nil
+	}
+
+	return reader.LoadTimestamps(path)
+}
+
+// fillTimestamp sets the timestamp of the timestamps file to an entry written without one
+func fillTimestamp(entry *supply.Info, timestamps map[uint64]uint64) {
+	if entry.Timestamp == 0 {
+		entry.Timestamp = timestamps[entry.Number]
+	}
+}
+
+// validationPolicy returns whether the entries failing validation are rejected, or only logged
+func validationPolicy(ctx *cli.Context) (failOnViolation bool, err error) {
+	switch policy := ctx.String(validationPolicyFlag.Name); policy {
+	case "fail":
+		return true, nil
+	case "log":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown validation policy %q", policy)
+	}
+}
+
+// loadSeed returns the seed of the state from the seed file and flags,
+// with the flags overriding the file. It returns nil when no seed is set.
+func loadSeed(ctx *cli.Context) (*tracker.Seed, error) {
+	var seed tracker.Seed
+
+	if path := ctx.String(seedFileFlag.Name); path != "" {
+		var err error
+		if seed, err = tracker.LoadSeed(path); err != nil {
+			return nil, err
+		}
+	} else if !ctx.IsSet(seedNumberFlag.Name) {
+		return nil, nil
+	}
+
+	if ctx.IsSet(seedNumberFlag.Name) {
+		seed.BlockNumber = ctx.Uint64(seedNumberFlag.Name)
+	}
+	if ctx.IsSet(seedHashFlag.Name) {
+		seed.Hash = common.HexToHash(ctx.String(seedHashFlag.Name))
+	}
+	if ctx.IsSet(seedSupplyFlag.Name) {
+		total, err := supply.ParseSignedBig([]byte(ctx.String(seedSupplyFlag.Name)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %v", seedSupplyFlag.Name, err)
+		}
+		seed.TotalSupply = total
+	}
+
+	return &seed, nil
+}
+
+// loadEras returns the eras from the custom eras or the network flags.
+// It returns nil when no eras are set.
+func loadEras(ctx *cli.Context) ([]tracker.Era, error) {
+	if spec := ctx.String(erasFlag.Name); spec != "" {
+		return tracker.ParseEras(spec)
+	}
+	if network := ctx.String(erasNetworkFlag.Name); network != "" {
+		eras, ok := tracker.NetworkEras[network]
+		if !ok {
+			return nil, fmt.Errorf("unknown network %q of --%s", network, erasNetworkFlag.Name)
+		}
+		return eras, nil
+	}
+
+	return nil, nil
+}
+
+// hasError checks if any of the violations has error severity
+func hasError(violations []validate.Violation) bool {
+	for _, v := range violations {
+		if v.Severity == validate.SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// newApp returns the app of the service and its commands
+func newApp() *cli.App {
 	app := &cli.App{
 		Name:  "supply-tracer-parser",
 		Usage: "Parse and sum supply data from a JSONL file",
 		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:  "supply.file",
-				Value: "supply.jsonl",
-				Usage: "File to read supply data from. Supports reading log rotated files.",
-			},
-			&cli.StringFlag{
-				Name:  "state.file",
-				Value: "state.json",
-				Usage: "File to store latest state for subsequent runs",
-			},
-			&cli.IntFlag{
-				Name:  "api.port",
-				Usage: "API port to expose the latest state",
-				Value: 8080,
-			},
-			&cli.BoolFlag{
-				Name:  "fresh",
-				Usage: "nuke the state and start fresh",
-			},
+			supplyFileFlag,
+			supplyURLFlag,
+			stateFileFlag,
+			apiPortFlag,
+			apiAddrFlag,
+			apiSocketFlag,
+			apiTLSCertFlag,
+			apiTLSKeyFlag,
+			apiReadTimeoutFlag,
+			apiWriteTimeoutFlag,
+			apiIdleTimeoutFlag,
+			apiAuthTokenFlag,
+			apiAuthSecretFlag,
+			apiAuthPublicFlag,
+			apiRateLimitFlag,
+			apiRateLimitBurstFlag,
+			apiRateLimitHeaderFlag,
+			apiCORSOriginsFlag,
+			validationPolicyFlag,
+			webhookURLFlag,
+			webhookSecretFlag,
+			webhookEveryFlag,
+			webhookReorgDepthFlag,
+			webhookStallFlag,
+			webhookRetriesFlag,
+			seedFileFlag,
+			seedNumberFlag,
+			seedHashFlag,
+			seedSupplyFlag,
+			reconcileAnchorAPIFlag,
+			erasNetworkFlag,
+			erasFlag,
+			timestampsFileFlag,
+			historyLimitFlag,
+			freshFlag,
+			configFileFlag,
 		},
 		Action: run,
+		Commands: []*cli.Command{
+			reconcileCommand,
+			exportCommand,
+			replayCommand,
+			inspectCommand,
+			verifyCommand,
+			diffCommand,
+			configCommand,
+		},
 	}
 
-	if err := app.Run(os.Args); err != nil {
+	return withConfig(app)
+}
+
+func main() {
+	if err := newApp().Run(os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,71 @@
+package reader
+
+import (
+	"fmt"
+
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+// EventKind is the type of an event emitted by a Source
+type EventKind int
+
+const (
+	// EventBlock carries a supply entry
+	EventBlock EventKind = iota
+	// EventCheckpoint marks a file as fully parsed, so that subsequent runs can resume after it
+	EventCheckpoint
+	// EventFileStart is emitted when a file is opened
+	EventFileStart
+	// EventFileEnd is emitted when a file has been read completely
+	EventFileEnd
+	// EventRotation is emitted when the live file has been rotated, before it is reopened
+	EventRotation
+	// EventError carries a reader error. No more events follow it.
+	EventError
+	// EventMalformed carries the error of a line that could not be decoded,
+	// when reading continues after it, see ScanFiles
+	EventMalformed
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventBlock:
+		return "block"
+	case EventCheckpoint:
+		return "checkpoint"
+	case EventFileStart:
+		return "file-start"
+	case EventFileEnd:
+		return "file-end"
+	case EventRotation:
+		return "rotation"
+	case EventError:
+		return "error"
+	case EventMalformed:
+		return "malformed"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(k))
+	}
+}
+
+// Position is the location in the source where an event originated from
+type Position struct {
+	File   string `json:"file"`   // File path, or stream URL
+	Line   uint64 `json:"line"`   // 1-based line number, 0 if not at a specific line
+	Offset int64  `json:"offset"` // Byte offset of the start of the line
+}
+
+func (p Position) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s (offset %d)", p.File, p.Offset)
+	}
+	return fmt.Sprintf("%s:%d (offset %d)", p.File, p.Line, p.Offset)
+}
+
+// Event is emitted by a Source
+type Event struct {
+	Kind   EventKind
+	Pos    Position
+	Supply supply.Info // Set for EventBlock
+	Err    error       // Set for EventError
+}
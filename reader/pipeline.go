@@ -0,0 +1,161 @@
+package reader
+
+import (
+	"runtime"
+)
+
+// decodeBatchSize is the number of lines decoded by a worker at once
+const decodeBatchSize = 256
+
+// decodeWorkers is the number of lines decoding workers of the file readers
+var decodeWorkers = runtime.GOMAXPROCS(0)
+
+// batch is a sequence of lines to decode, or of events that need no decoding
+type batch struct {
+	lines  [][]byte
+	pos    []Position
+	events []Event
+	done   chan struct{} // Closed when the events are ready
+}
+
+// pipeline decodes lines in parallel and emits their events in the order they were read.
+// Lines are decoded by batches, and events that need no decoding are emitted in order with them.
+// After an error event, no more events are emitted.
+// When lenient is set, malformed lines are emitted as EventMalformed events instead of errors.
+type pipeline struct {
+	eventsCh chan<- Event
+	lenient  bool
+
+	work    chan *batch // Batches to decode
+	ordered chan *batch // Batches in the order they were read
+
+	current *batch
+
+	failed   chan struct{} // Closed when an error event has been emitted
+	finished chan struct{} // Closed when all the batches have been emitted
+}
+
+func newPipeline(eventsCh chan<- Event, workers int, lenient bool) *pipeline {
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &pipeline{
+		eventsCh: eventsCh,
+		lenient:  lenient,
+		work:     make(chan *batch, workers),
+		ordered:  make(chan *batch, 2*workers),
+		failed:   make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+
+	for i := 0; i < workers; i++ {
+		go p.decode()
+	}
+	go p.emit()
+
+	return p
+}
+
+// decode decodes the lines of the batches to events
+func (p *pipeline) decode() {
+	for b := range p.work {
+		b.events = make([]Event, 0, len(b.lines))
+		for i, line := range b.lines {
+			if event, ok := decodeLine(line, b.pos[i]); ok {
+				if event.Kind == EventError && p.lenient {
+					event.Kind = EventMalformed
+				}
+				b.events = append(b.events, event)
+				if event.Kind == EventError {
+					break
+				}
+			}
+		}
+		close(b.done)
+	}
+}
+
+// emit emits the events of the batches in order, until an error event
+func (p *pipeline) emit() {
+	defer close(p.finished)
+
+	stopped := false
+	for b := range p.ordered {
+		<-b.done
+		if stopped {
+			continue
+		}
+		for _, event := range b.events {
+			p.eventsCh <- event
+			if event.Kind == EventError {
+				stopped = true
+				close(p.failed)
+				break
+			}
+		}
+	}
+}
+
+// line adds a line to be decoded. The line is copied.
+// It returns false when an error event has been emitted, so reading should stop.
+func (p *pipeline) line(line []byte, pos Position) bool {
+	if p.current == nil {
+		p.current = &batch{
+			lines: make([][]byte, 0, decodeBatchSize),
+			pos:   make([]Position, 0, decodeBatchSize),
+			done:  make(chan struct{}),
+		}
+	}
+	p.current.lines = append(p.current.lines, append([]byte(nil), line...))
+	p.current.pos = append(p.current.pos, pos)
+
+	if len(p.current.lines) == decodeBatchSize {
+		p.flush()
+	}
+
+	return !p.isFailed()
+}
+
+// event adds an event that needs no decoding, after the lines added so far.
+// It returns false when an error event has been emitted, so reading should stop.
+func (p *pipeline) event(event Event) bool {
+	p.flush()
+
+	b := &batch{events: []Event{event}, done: make(chan struct{})}
+	close(b.done)
+	p.ordered <- b
+
+	return !p.isFailed()
+}
+
+// flush sends the pending lines to be decoded, e.g. before waiting for more lines
+func (p *pipeline) flush() {
+	if p.current == nil {
+		return
+	}
+
+	b := p.current
+	p.current = nil
+
+	p.work <- b
+	p.ordered <- b
+}
+
+// close decodes the pending lines and waits until all the events have been emitted
+func (p *pipeline) close() {
+	p.flush()
+	close(p.work)
+	close(p.ordered)
+
+	<-p.finished
+}
+
+func (p *pipeline) isFailed() bool {
+	select {
+	case <-p.failed:
+		return true
+	default:
+		return false
+	}
+}
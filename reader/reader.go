@@ -0,0 +1,244 @@
+// Package reader reads the supply data written by the supply tracer of go-ethereum,
+// from log rotated JSONL files or streams, and emits it as events.
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+// FindAndSortLogFiles returns the names of the log rotated files of file in dir,
+// sorted so that the live file comes last.
+func FindAndSortLogFiles(dir, file string) ([]string, error) {
+	if dir == "" {
+		dir = "."
+	}
+
+	fileExtension := filepath.Ext(file)
+	filename := strings.TrimSuffix(file, fileExtension)
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var logFiles []string
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), filename) && strings.HasSuffix(f.Name(), fileExtension) {
+			logFiles = append(logFiles, f.Name())
+		}
+	}
+	sort.Slice(logFiles, func(i, j int) bool {
+		return logFiles[i] < logFiles[j]
+	})
+	return logFiles, nil
+}
+
+// ReadFileStream reads supply data from the specified file.
+// It supports reading log rotated files.
+func ReadFileStream(path, skipUntilFile string) (<-chan Event, error) {
+	return readLogFiles(path, skipUntilFile, true, true, false)
+}
+
+// ReadFiles reads the supply data of the log rotated files of the specified file once,
+// skipping the files up to and including skipUntilFile. The live file is read up to its end
+// when live is set, without waiting for more lines to be appended.
+// The channel is closed when all files have been read.
+func ReadFiles(path, skipUntilFile string, live bool) (<-chan Event, error) {
+	return readLogFiles(path, skipUntilFile, false, live, false)
+}
+
+// ScanFiles reads the supply data of all the log rotated files of the specified file once,
+// the live file included. Unlike ReadFiles, it does not stop at malformed lines,
+// which are emitted as EventMalformed events.
+func ScanFiles(path string) (<-chan Event, error) {
+	return readLogFiles(path, "", false, true, true)
+}
+
+// readLogFiles reads the log rotated files of path, skipping the files up to and including skipUntilFile.
+// When follow is set, the live file is followed for new lines and across rotations,
+// otherwise it is only read when live is set. When lenient is set, malformed lines do not stop the reading.
+func readLogFiles(path, skipUntilFile string, follow, live, lenient bool) (<-chan Event, error) {
+	dir, originalFile := filepath.Split(path)
+
+	files, err := FindAndSortLogFiles(dir, originalFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list and sort log files: %v", err)
+	}
+
+	// Skip until the designated file
+	skipping := true
+
+	// If skipUntilFile is empty, don't skip any files
+	if skipUntilFile == "" {
+		skipping = false
+	}
+
+	eventsCh := make(chan Event, 1024)
+
+	go func() {
+		defer close(eventsCh)
+
+		p := newPipeline(eventsCh, decodeWorkers, lenient)
+		defer p.close()
+
+		for _, fileName := range files {
+			if skipping {
+				if fileName == skipUntilFile {
+					skipping = false
+				}
+
+				continue
+			}
+
+			if !follow && !live && fileName == originalFile {
+				continue
+			}
+
+			filePath := filepath.Join(dir, fileName)
+			waitForMore := follow && fileName == originalFile
+
+			// The live file is reopened every time it gets rotated
+			for {
+				rotated, ok := processLogFile(filePath, waitForMore, p)
+				if !ok {
+					return
+				}
+				if !rotated {
+					break
+				}
+			}
+		}
+	}()
+
+	return eventsCh, nil
+}
+
+// processLogFile reads the supply data of a file, and decodes it with the pipeline.
+// When waitForMore is set, it keeps waiting for new lines to be appended
+// until the file gets rotated. It returns false when an error event was emitted.
+func processLogFile(path string, waitForMore bool, p *pipeline) (rotated bool, ok bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		p.event(Event{Kind: EventError, Pos: Position{File: path}, Err: fmt.Errorf("failed to open file %s: %v", path, err)})
+		return false, false
+	}
+	defer file.Close()
+
+	pos := Position{File: path}
+	if !p.event(Event{Kind: EventFileStart, Pos: pos}) {
+		return false, false
+	}
+
+	var line []byte
+	reader := bufio.NewReader(file)
+	for {
+		chunk, err := reader.ReadBytes('\n')
+		line = append(line, chunk...)
+
+		if err != nil && err != io.EOF {
+			p.event(Event{Kind: EventError, Pos: pos, Err: fmt.Errorf("error reading file: %v", err)})
+			return false, false
+		}
+
+		// A line is complete when it's newline terminated, or it's the last line of a finished file.
+		// Partial lines of the live file are kept until the rest of the line is written.
+		if err == nil || (!waitForMore && len(line) > 0) {
+			linePos := Position{File: pos.File, Line: pos.Line + 1, Offset: pos.Offset}
+			pos.Line++
+			pos.Offset += int64(len(line))
+
+			if !p.line(line, linePos) {
+				return false, false
+			}
+			line = line[:0]
+
+			continue
+		}
+
+		// EOF is reached
+		if !waitForMore {
+			ok := p.event(Event{Kind: EventFileEnd, Pos: pos})
+
+			// Save state when we finish reading a file
+			// skip the live file, where we "waitForMore"
+			if !rotated || pos.File != path {
+				ok = ok && p.event(Event{Kind: EventCheckpoint, Pos: pos})
+			}
+			if rotated {
+				ok = ok && p.event(Event{Kind: EventRotation, Pos: pos})
+			}
+
+			return rotated, ok
+		}
+
+		rotatedPath, isRotated := findRotatedFile(file, path)
+		if isRotated {
+			// Read the lines that were written before the rotation and finish the file
+			rotated = true
+			waitForMore = false
+			if rotatedPath != "" {
+				pos.File = rotatedPath
+			}
+
+			continue
+		}
+
+		// Emit the lines read so far, and wait for new lines to be appended
+		p.flush()
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// decodeLine unmarshals a line to a block event, or an error event when it is malformed.
+// It returns false for empty lines.
+func decodeLine(line []byte, pos Position) (Event, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return Event{}, false
+	}
+
+	var entry supply.Info
+	if err := entry.UnmarshalJSON(line); err != nil {
+		return Event{Kind: EventError, Pos: pos, Err: fmt.Errorf("error unmarshalling line: %v", err)}, true
+	}
+
+	return Event{Kind: EventBlock, Pos: pos, Supply: entry}, true
+}
+
+// findRotatedFile checks if the opened file is no longer the one at path,
+// and returns the path it has been rotated to, if it can be found.
+// A missing file at path is not considered a rotation, until the new file is created.
+func findRotatedFile(file *os.File, path string) (string, bool) {
+	current, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	opened, err := file.Stat()
+	if err != nil || os.SameFile(current, opened) {
+		return "", false
+	}
+
+	dir, originalFile := filepath.Split(path)
+	files, err := FindAndSortLogFiles(dir, originalFile)
+	if err != nil {
+		return "", true
+	}
+	for _, fileName := range files {
+		candidatePath := filepath.Join(dir, fileName)
+		candidate, err := os.Stat(candidatePath)
+		if err == nil && os.SameFile(candidate, opened) {
+			return candidatePath, true
+		}
+	}
+
+	return "", true
+}
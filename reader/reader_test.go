@@ -0,0 +1,370 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+const (
+	fileTestBlock0 = `{"blockNumber":0,"hash":"0x0100000000000000000000000000000000000000000000000000000000000000","parentHash":"0x0000000000000000000000000000000000000000000000000000000000000000","issuance":{"genesisAlloc":"0x1"}}` + "\n"
+	fileTestBlock1 = `{"blockNumber":1,"hash":"0x0200000000000000000000000000000000000000000000000000000000000000","parentHash":"0x0100000000000000000000000000000000000000000000000000000000000000","issuance":{"reward":"0x2"}}` + "\n"
+	fileTestBlock2 = `{"blockNumber":2,"hash":"0x0300000000000000000000000000000000000000000000000000000000000000","parentHash":"0x0200000000000000000000000000000000000000000000000000000000000000","issuance":{"reward":"0x2"}}` + "\n"
+)
+
+// nextEvent waits for the next event that is not a file-start or file-end event
+func nextEvent(t *testing.T, eventsCh <-chan Event) Event {
+	t.Helper()
+
+	for {
+		select {
+		case event := <-eventsCh:
+			if event.Kind == EventFileStart || event.Kind == EventFileEnd {
+				continue
+			}
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+func TestReadFileStreamPositions(t *testing.T) {
+	dir := t.TempDir()
+	rotatedPath := filepath.Join(dir, "supply-2024-01-01T00-00-00.000.jsonl")
+	livePath := filepath.Join(dir, "supply.jsonl")
+
+	if err := os.WriteFile(rotatedPath, []byte(fileTestBlock0+"\n"+fileTestBlock1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(livePath, []byte(fileTestBlock2), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	eventsCh, err := ReadFileStream(livePath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		kind   EventKind
+		pos    Position
+		number uint64
+	}{
+		{EventBlock, Position{File: rotatedPath, Line: 1, Offset: 0}, 0},
+		{EventBlock, Position{File: rotatedPath, Line: 3, Offset: int64(len(fileTestBlock0)) + 1}, 1},
+		{EventCheckpoint, Position{File: rotatedPath, Line: 3, Offset: int64(len(fileTestBlock0+fileTestBlock1)) + 1}, 0},
+		{EventBlock, Position{File: livePath, Line: 1, Offset: 0}, 2},
+	}
+	for i, w := range want {
+		event := nextEvent(t, eventsCh)
+		if event.Kind != w.kind || event.Pos != w.pos {
+			t.Fatalf("event %d: want %v at %s, have %v at %s", i, w.kind, w.pos, event.Kind, event.Pos)
+		}
+		if event.Kind == EventBlock && event.Supply.Number != w.number {
+			t.Errorf("event %d: want block %d, have %d", i, w.number, event.Supply.Number)
+		}
+	}
+}
+
+func TestReadFileStreamSkipUntilFile(t *testing.T) {
+	dir := t.TempDir()
+	rotatedPath := filepath.Join(dir, "supply-2024-01-01T00-00-00.000.jsonl")
+	livePath := filepath.Join(dir, "supply.jsonl")
+
+	if err := os.WriteFile(rotatedPath, []byte(fileTestBlock0+fileTestBlock1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(livePath, []byte(fileTestBlock2), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	eventsCh, err := ReadFileStream(livePath, filepath.Base(rotatedPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := nextEvent(t, eventsCh)
+	if event.Kind != EventBlock || event.Supply.Number != 2 {
+		t.Errorf("want block 2, have %v %d", event.Kind, event.Supply.Number)
+	}
+}
+
+func TestReadFileStreamRotation(t *testing.T) {
+	dir := t.TempDir()
+	livePath := filepath.Join(dir, "supply.jsonl")
+	rotatedPath := filepath.Join(dir, "supply-2024-01-01T00-00-00.000.jsonl")
+
+	// Start with a partial line, which must wait for the rest of it
+	if err := os.WriteFile(livePath, []byte(fileTestBlock0[:10]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	eventsCh, err := ReadFileStream(livePath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(livePath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(fileTestBlock0[10:] + fileTestBlock1); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for _, number := range []uint64{0, 1} {
+		event := nextEvent(t, eventsCh)
+		if event.Kind != EventBlock || event.Supply.Number != number {
+			t.Fatalf("want block %d, have %v %d (%v)", number, event.Kind, event.Supply.Number, event.Err)
+		}
+	}
+
+	// Rotate the live file and write the next block to the new one
+	if err := os.Rename(livePath, rotatedPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(livePath, []byte(fileTestBlock2), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	event := nextEvent(t, eventsCh)
+	if event.Kind != EventCheckpoint || event.Pos.File != rotatedPath {
+		t.Fatalf("want checkpoint of %s, have %v at %s", rotatedPath, event.Kind, event.Pos)
+	}
+	event = nextEvent(t, eventsCh)
+	if event.Kind != EventRotation {
+		t.Fatalf("want rotation, have %v at %s", event.Kind, event.Pos)
+	}
+	event = nextEvent(t, eventsCh)
+	if event.Kind != EventBlock || event.Supply.Number != 2 || event.Pos.File != livePath || event.Pos.Line != 1 {
+		t.Fatalf("want block 2 at %s:1, have %v %d at %s", livePath, event.Kind, event.Supply.Number, event.Pos)
+	}
+}
+
+func TestLoadTimestamps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timestamps.csv")
+	if err := os.WriteFile(path, []byte("# number,timestamp\n1,1438269988\n\n2, 1438270017\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	timestamps, err := LoadTimestamps(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timestamps) != 2 || timestamps[1] != 1438269988 || timestamps[2] != 1438270017 {
+		t.Errorf("unexpected timestamps: %v", timestamps)
+	}
+
+	if err := os.WriteFile(path, []byte("1;1438269988\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTimestamps(path); err == nil {
+		t.Errorf("expected error for an invalid line")
+	}
+}
+
+// writeBlocks writes a file of n supply lines, and the malformed line at line bad when it is positive
+func writeBlocks(tb testing.TB, path string, n, bad int) {
+	tb.Helper()
+
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		if i+1 == bad {
+			buf.WriteString("{malformed\n")
+			continue
+		}
+		fmt.Fprintf(&buf, `{"blockNumber":%d,"hash":"0x%064x","parentHash":"0x%064x","issuance":{"reward":"0x2"},"burn":{"eip1559":"0x1"}}`+"\n", i, i+1, i)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestReadFilesOrder(t *testing.T) {
+	dir := t.TempDir()
+	livePath := filepath.Join(dir, "supply.jsonl")
+	writeBlocks(t, filepath.Join(dir, "supply-2024-01-01T00-00-00.000.jsonl"), 3*decodeBatchSize+7, 0)
+	writeBlocks(t, livePath, 10, 0)
+
+	eventsCh, err := ReadFiles(livePath, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []EventKind
+	var numbers []uint64
+	for event := range eventsCh {
+		kinds = append(kinds, event.Kind)
+		if event.Kind == EventBlock {
+			if event.Pos.Line != event.Supply.Number+1 {
+				t.Fatalf("block %d at line %d", event.Supply.Number, event.Pos.Line)
+			}
+			numbers = append(numbers, event.Supply.Number)
+		}
+	}
+
+	if len(numbers) != 3*decodeBatchSize+7+10 {
+		t.Fatalf("want %d blocks, have %d", 3*decodeBatchSize+7+10, len(numbers))
+	}
+	for i, number := range numbers {
+		want := uint64(i)
+		if i >= 3*decodeBatchSize+7 {
+			want = uint64(i - 3*decodeBatchSize - 7)
+		}
+		if number != want {
+			t.Fatalf("block %d: want number %d, have %d", i, want, number)
+		}
+	}
+
+	// The file events are emitted in order with the blocks
+	if kinds[0] != EventFileStart || kinds[3*decodeBatchSize+8] != EventFileEnd || kinds[3*decodeBatchSize+9] != EventCheckpoint || kinds[3*decodeBatchSize+10] != EventFileStart {
+		t.Errorf("file events out of order with the blocks")
+	}
+}
+
+func TestReadFilesStopsOnError(t *testing.T) {
+	dir := t.TempDir()
+	livePath := filepath.Join(dir, "supply.jsonl")
+	writeBlocks(t, filepath.Join(dir, "supply-2024-01-01T00-00-00.000.jsonl"), 2*decodeBatchSize, decodeBatchSize+3)
+	writeBlocks(t, livePath, 10, 0)
+
+	eventsCh, err := ReadFiles(livePath, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := 0
+	var last Event
+	for event := range eventsCh {
+		if event.Kind == EventBlock {
+			blocks++
+		}
+		last = event
+	}
+
+	if last.Kind != EventError || last.Pos.Line != decodeBatchSize+3 {
+		t.Fatalf("want the error at line %d last, have %v at %s", decodeBatchSize+3, last.Kind, last.Pos)
+	}
+	if blocks != decodeBatchSize+2 {
+		t.Errorf("want %d blocks before the error, have %d", decodeBatchSize+2, blocks)
+	}
+}
+
+func TestScanFilesMalformed(t *testing.T) {
+	dir := t.TempDir()
+	livePath := filepath.Join(dir, "supply.jsonl")
+	writeBlocks(t, filepath.Join(dir, "supply-2024-01-01T00-00-00.000.jsonl"), 10, 4)
+	writeBlocks(t, livePath, 10, 0)
+
+	eventsCh, err := ScanFiles(livePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := 0
+	var malformed []Event
+	for event := range eventsCh {
+		switch event.Kind {
+		case EventBlock:
+			blocks++
+		case EventMalformed:
+			malformed = append(malformed, event)
+		case EventError:
+			t.Fatalf("unexpected error %v at %s", event.Err, event.Pos)
+		}
+	}
+
+	if len(malformed) != 1 || malformed[0].Pos.Line != 4 || malformed[0].Err == nil {
+		t.Fatalf("want a malformed line 4, have %v", malformed)
+	}
+	if blocks != 19 {
+		t.Errorf("want the 19 blocks around the malformed line, have %d", blocks)
+	}
+}
+
+// readSerial reads and decodes the lines of the file one after the other,
+// as the files were read before the pipeline. It is the baseline of BenchmarkReadFiles.
+func readSerial(b *testing.B, path string) <-chan Event {
+	file, err := os.Open(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	eventsCh := make(chan Event, 1024)
+	go func() {
+		defer close(eventsCh)
+		defer file.Close()
+
+		pos := Position{File: path}
+		reader := bufio.NewReader(file)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 && err == nil {
+				pos.Line++
+				if event, ok := decodeLine(line, pos); ok {
+					eventsCh <- event
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return eventsCh
+}
+
+// BenchmarkReadFiles compares the serial reading of a file with the pipeline,
+// decoding on as many workers as GOMAXPROCS:
+//
+//	go test ./reader -run xxx -bench ReadFiles -cpu 1,2,4,8
+func BenchmarkReadFiles(b *testing.B) {
+	const lines = 20000
+
+	path := filepath.Join(b.TempDir(), "supply.jsonl")
+	writeBlocks(b, path, lines, 0)
+	info, err := os.Stat(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	read := func(b *testing.B, readFile func() <-chan Event) {
+		b.SetBytes(info.Size())
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			blocks := 0
+			for event := range readFile() {
+				if event.Kind == EventBlock {
+					blocks++
+				}
+			}
+			if blocks != lines {
+				b.Fatalf("want %d blocks, have %d", lines, blocks)
+			}
+		}
+		b.ReportMetric(float64(lines*b.N)/b.Elapsed().Seconds(), "blocks/s")
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		read(b, func() <-chan Event { return readSerial(b, path) })
+	})
+	b.Run("pipeline", func(b *testing.B) {
+		defer func(w int) { decodeWorkers = w }(decodeWorkers)
+		decodeWorkers = runtime.GOMAXPROCS(0)
+
+		read(b, func() <-chan Event {
+			eventsCh, err := ReadFiles(path, "", true)
+			if err != nil {
+				b.Fatal(err)
+			}
+			return eventsCh
+		})
+	})
+}
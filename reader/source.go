@@ -0,0 +1,253 @@
+package reader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Source is a provider of supply data entries.
+// Started sources emit block events and, when they can be resumed,
+// checkpoint events on the returned channel. The channel is closed
+// after an error event.
+type Source interface {
+	Start() (<-chan Event, error)
+}
+
+// FileSource reads supply data from the JSONL files written by
+// `geth --vmtrace supply`, including the log rotated ones.
+type FileSource struct {
+	path          string
+	skipUntilFile string
+}
+
+// NewFileSource returns a Source reading from the log rotated files of path,
+// skipping the files up to and including skipUntilFile.
+func NewFileSource(path, skipUntilFile string) *FileSource {
+	return &FileSource{
+		path:          path,
+		skipUntilFile: skipUntilFile,
+	}
+}
+
+// Start implements Source.
+func (f *FileSource) Start() (<-chan Event, error) {
+	return ReadFileStream(f.path, f.skipUntilFile)
+}
+
+// streamRetryDelay is the time to wait before reconnecting to a stream
+const streamRetryDelay = 1 * time.Second
+
+// streamCheckpointBlocks is the number of blocks read from a stream between checkpoints
+const streamCheckpointBlocks = 128
+
+// StreamSource reads newline delimited supply data from a stream.
+// Supported URL schemes are:
+//   - unix:///path/to/supply.sock for Unix domain sockets
+//   - tcp://host:port for plain TCP connections
+//   - http(s)://host:port/path for NDJSON HTTP endpoints
+//   - file:///path/to/supply.pipe for named pipes
+//
+// The stream is reopened whenever it ends, as the producer is expected
+// to come back, e.g. when geth is restarted. As a stream cannot be resumed
+// from a position, checkpoints are emitted every streamCheckpointBlocks blocks,
+// when the stream ends and when the source is closed, to save the state.
+type StreamSource struct {
+	url             *url.URL
+	client          *http.Client
+	retryDelay      time.Duration
+	checkpointEvery int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	stream io.Closer // Stream being read, closed to stop reading
+}
+
+// NewStreamSource returns a Source reading from the stream at rawURL
+func NewStreamSource(rawURL string) (*StreamSource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stream url %q: %v", rawURL, err)
+	}
+
+	switch u.Scheme {
+	case "unix", "file":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid stream url %q: missing path", rawURL)
+		}
+	case "tcp", "http", "https":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid stream url %q: missing host", rawURL)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported stream url scheme %q", u.Scheme)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &StreamSource{
+		url:             u,
+		client:          &http.Client{},
+		retryDelay:      streamRetryDelay,
+		checkpointEvery: streamCheckpointBlocks,
+		ctx:             ctx,
+		cancel:          cancel,
+	}, nil
+}
+
+// Start implements Source.
+func (s *StreamSource) Start() (<-chan Event, error) {
+	eventsCh := make(chan Event, 1024)
+
+	go func() {
+		defer close(eventsCh)
+
+		for s.ctx.Err() == nil {
+			stream, err := s.open()
+			if err != nil {
+				if s.ctx.Err() == nil {
+					log.Printf("Failed to open supply stream %s: %v", s.url, err)
+				}
+			} else if s.setStream(stream) {
+				ok := s.read(stream, eventsCh)
+				s.setStream(nil)
+				stream.Close()
+
+				// Malformed data can't be skipped, same as with files
+				if !ok {
+					return
+				}
+				if s.ctx.Err() != nil {
+					return
+				}
+				log.Printf("Supply stream %s ended, reconnecting...", s.url)
+			}
+
+			select {
+			case <-s.ctx.Done():
+			case <-time.After(s.retryDelay):
+			}
+		}
+	}()
+
+	return eventsCh, nil
+}
+
+// Close stops reading the stream. The events channel is closed after a last checkpoint.
+func (s *StreamSource) Close() error {
+	s.cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stream != nil {
+		return s.stream.Close()
+	}
+	return nil
+}
+
+// setStream sets the stream being read. It returns false, and closes the stream,
+// when the source has been closed.
+func (s *StreamSource) setStream(stream io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stream != nil && s.ctx.Err() != nil {
+		stream.Close()
+		return false
+	}
+	s.stream = stream
+
+	return true
+}
+
+// open connects to the stream
+func (s *StreamSource) open() (io.ReadCloser, error) {
+	var dialer net.Dialer
+	switch s.url.Scheme {
+	case "unix":
+		return dialer.DialContext(s.ctx, "unix", s.url.Path)
+	case "tcp":
+		return dialer.DialContext(s.ctx, "tcp", s.url.Host)
+	case "file":
+		return os.Open(s.url.Path)
+	}
+
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, s.url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// read reads supply data from r until it ends, and emits a checkpoint
+// every checkpointEvery blocks and at the end of the stream.
+// Read errors are treated as the end of the stream, as connections may drop,
+// and an unterminated last line is dropped, while unmarshalling errors are emitted. It returns false when an error event was emitted.
+func (s *StreamSource) read(r io.Reader, eventsCh chan<- Event) bool {
+	pos := Position{File: s.url.String()}
+	blocks := 0 // Blocks since the last checkpoint
+
+	checkpoint := func() {
+		if blocks > 0 {
+			eventsCh <- Event{Kind: EventCheckpoint, Pos: pos}
+			blocks = 0
+		}
+	}
+	defer checkpoint()
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		// Drop the partial line of a dropped connection, or of a stream ending within a line
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Printf("Dropping the unterminated last line of the supply stream at %s", Position{File: pos.File, Line: pos.Line + 1, Offset: pos.Offset})
+			}
+			return true
+		}
+		if err != nil {
+			if s.ctx.Err() == nil {
+				log.Printf("Error reading supply stream: %v", err)
+			}
+			return true
+		}
+
+		linePos := Position{File: pos.File, Line: pos.Line + 1, Offset: pos.Offset}
+		pos.Line++
+		pos.Offset += int64(len(line))
+
+		if event, ok := decodeLine(line, linePos); ok {
+			if event.Kind == EventError {
+				blocks = 0 // The entries before the error are not checkpointed
+				eventsCh <- event
+				return false
+			}
+			eventsCh <- event
+
+			if blocks++; blocks >= s.checkpointEvery {
+				checkpoint()
+			}
+		}
+	}
+}
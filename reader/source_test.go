@@ -0,0 +1,226 @@
+package reader
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+const streamTestData = `{"blockNumber":0,"hash":"0x0100000000000000000000000000000000000000000000000000000000000000","parentHash":"0x0000000000000000000000000000000000000000000000000000000000000000","issuance":{"genesisAlloc":"0x1"}}
+
+{"blockNumber":1,"hash":"0x0200000000000000000000000000000000000000000000000000000000000000","parentHash":"0x0100000000000000000000000000000000000000000000000000000000000000","issuance":{"reward":"0x2"},"burn":{"eip1559":"0x1"}}
+`
+
+// readEntries reads n entries from the source channel, failing on errors
+func readEntries(t *testing.T, eventsCh <-chan Event, n int) []supply.Info {
+	t.Helper()
+
+	var entries []supply.Info
+	for len(entries) < n {
+		select {
+		case event := <-eventsCh:
+			switch event.Kind {
+			case EventBlock:
+				entries = append(entries, event.Supply)
+			case EventError:
+				t.Fatalf("unexpected error: %v", event.Err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for entries, have %d want %d", len(entries), n)
+		}
+	}
+
+	return entries
+}
+
+func verifyStreamEntries(t *testing.T, entries []supply.Info) {
+	t.Helper()
+
+	if entries[0].Number != 0 || entries[0].Hash != (common.Hash{1}) || entries[0].Delta.Int64() != 1 {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].Number != 1 || entries[1].ParentHash != (common.Hash{1}) || entries[1].Delta.Int64() != 1 {
+		t.Errorf("unexpected second entry: %+v", entries[1])
+	}
+}
+
+func TestStreamSourceHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprint(w, streamTestData)
+	}))
+	defer server.Close()
+
+	source, err := NewStreamSource(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.retryDelay = 10 * time.Millisecond
+
+	eventsCh, err := source.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifyStreamEntries(t, readEntries(t, eventsCh, 2))
+
+	// The stream reconnects once the response ends
+	verifyStreamEntries(t, readEntries(t, eventsCh, 2))
+}
+
+func TestStreamSourceUnix(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "supply.sock")
+	listener, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, streamTestData)
+	}()
+
+	source, err := NewStreamSource("unix://" + sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventsCh, err := source.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifyStreamEntries(t, readEntries(t, eventsCh, 2))
+}
+
+func TestStreamSourceCheckpointAndClose(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "supply.sock")
+	listener, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The connection stays open, until the source is closed
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, streamTestData+streamTestData)
+		conn.Read(make([]byte, 1))
+	}()
+
+	source, err := NewStreamSource("unix://" + sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.checkpointEvery = 3
+
+	eventsCh, err := source.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []EventKind
+	next := func() bool {
+		select {
+		case event, ok := <-eventsCh:
+			if ok {
+				kinds = append(kinds, event.Kind)
+			}
+			return ok
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events, have %v", kinds)
+			return false
+		}
+	}
+	for len(kinds) < 5 {
+		next()
+	}
+
+	// The blocks after the last checkpoint are checkpointed on close
+	source.Close()
+	for next() {
+	}
+
+	want := []EventKind{EventBlock, EventBlock, EventBlock, EventCheckpoint, EventBlock, EventCheckpoint}
+	if fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Errorf("want events %v, have %v", want, kinds)
+	}
+}
+
+func TestStreamSourceMalformedLine(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "{not json")
+	}))
+	defer server.Close()
+
+	source, err := NewStreamSource(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventsCh, err := source.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-eventsCh:
+		if event.Kind != EventError || !strings.HasPrefix(event.Err.Error(), "error unmarshalling line") {
+			t.Errorf("unexpected event: %v %v", event.Kind, event.Err)
+		}
+		if event.Pos.Line != 1 || event.Pos.Offset != 0 {
+			t.Errorf("unexpected error position: %s", event.Pos)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for unmarshalling error")
+	}
+}
+
+func TestStreamSourceUnterminatedLine(t *testing.T) {
+	// The stream ends within the last line, e.g. when the tracer is stopped while writing it
+	data := streamTestData + `{"blockNumber":2,"hash":"0x03`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, data)
+	}))
+	defer server.Close()
+
+	source, err := NewStreamSource(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.retryDelay = 10 * time.Millisecond
+
+	eventsCh, err := source.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The partial line is dropped and the stream reconnects
+	verifyStreamEntries(t, readEntries(t, eventsCh, 2))
+	verifyStreamEntries(t, readEntries(t, eventsCh, 2))
+}
+
+func TestNewStreamSourceInvalidURL(t *testing.T) {
+	for _, rawURL := range []string{"ftp://host/file", "unix://", "http:///path"} {
+		if _, err := NewStreamSource(rawURL); err == nil {
+			t.Errorf("expected error for url %q", rawURL)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package reader
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadTimestamps reads a sidecar file of block timestamps, for traces without them.
+// Each line is a block number and its unix time, separated by a comma, e.g. "17034870,1681338455".
+// Empty lines and lines starting with '#' are skipped.
+func LoadTimestamps(path string) (map[uint64]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open timestamps file: %v", err)
+	}
+	defer file.Close()
+
+	timestamps := make(map[uint64]uint64)
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		number, timestamp, ok := strings.Cut(text, ",")
+		if !ok {
+			return nil, fmt.Errorf("invalid timestamp at %s:%d, want number,timestamp", path, line)
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(number), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block number at %s:%d: %v", path, line, err)
+		}
+		ts, err := strconv.ParseUint(strings.TrimSpace(timestamp), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp at %s:%d: %v", path, line, err)
+		}
+		timestamps[n] = ts
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read timestamps file: %v", err)
+	}
+
+	return timestamps, nil
+}
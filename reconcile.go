@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+var (
+	reconcileKnownFlag = &cli.StringFlag{
+		Name:     "known",
+		Usage:    "JSON file with the known total supply at a block: {\"blockNumber\": ..., \"hash\": ..., \"totalSupply\": ...}",
+		Required: true,
+	}
+	reconcileAnchorFlag = &cli.BoolFlag{
+		Name:  "anchor",
+		Usage: "Anchor the total supply of the state file to the known one",
+	}
+
+	reconcileCommand = &cli.Command{
+		Name:  "reconcile",
+		Usage: "Compare the total supply of the state file with a known one",
+		Description: `The known total supply has to be at the block of the state file, as the state file keeps no history.
+To reconcile at an older block, use the /reconcile endpoint of the running API.`,
+		Flags: []cli.Flag{
+			stateFileFlag,
+			reconcileKnownFlag,
+			reconcileAnchorFlag,
+		},
+		Action: reconcile,
+	}
+)
+
+func reconcile(ctx *cli.Context) error {
+	stateFilePath := ctx.String(stateFileFlag.Name)
+
+	known, err := tracker.LoadKnownSupply(ctx.String(reconcileKnownFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	state := tracker.NewState()
+	lastParsedFile, err := state.LoadState(stateFilePath)
+	if err != nil {
+		return err
+	}
+
+	result, err := state.Reconcile(known, ctx.Bool(reconcileAnchorFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	if result.Anchored {
+		state.SaveState(stateFilePath, lastParsedFile)
+	}
+
+	out, err := json.MarshalIndent(&result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal reconciliation: %v", err)
+	}
+	fmt.Fprintln(os.Stdout, string(out))
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"github.com/ziogaschr/supply-tracer-parser/reader"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+	"github.com/ziogaschr/supply-tracer-parser/validate"
+)
+
+var (
+	replayResumeFlag = &cli.BoolFlag{
+		Name:  "resume",
+		Usage: "Resume from the state file, after its last parsed file, instead of starting over",
+	}
+	replayLiveFlag = &cli.BoolFlag{
+		Name:  "live",
+		Usage: "Replay the live file too. The state then records it as parsed, so the service would not resume from it",
+	}
+	replayProgressFlag = &cli.DurationFlag{
+		Name:  "progress",
+		Usage: "Interval of the progress reports",
+		Value: 10 * time.Second,
+	}
+
+	replayCommand = &cli.Command{
+		Name:  "replay",
+		Usage: "Rebuild the state file from the log rotated supply files and exit",
+		Description: `Reads the log rotated files once, without tailing the live file, and writes the state file
+after each file and at the end. The live file is skipped unless --live is set,
+so that the service resumes reading it from its start.`,
+		Flags: []cli.Flag{
+			supplyFileFlag,
+			stateFileFlag,
+			timestampsFileFlag,
+			validationPolicyFlag,
+			seedFileFlag,
+			seedNumberFlag,
+			seedHashFlag,
+			seedSupplyFlag,
+			erasNetworkFlag,
+			erasFlag,
+			historyLimitFlag,
+			replayResumeFlag,
+			replayLiveFlag,
+			replayProgressFlag,
+		},
+		Action: replay,
+	}
+)
+
+func replay(ctx *cli.Context) error {
+	supplyFilePath := ctx.String(supplyFileFlag.Name)
+	stateFilePath := ctx.String(stateFileFlag.Name)
+
+	state := tracker.NewState()
+
+	var lastParsedFile string
+	if ctx.Bool(replayResumeFlag.Name) {
+		var err error
+		if lastParsedFile, err = state.LoadState(stateFilePath); err != nil {
+			return err
+		}
+	} else {
+		seed, err := loadSeed(ctx)
+		if err != nil {
+			return err
+		}
+		if seed != nil {
+			if state, err = tracker.NewSeededState(*seed); err != nil {
+				return err
+			}
+		}
+	}
+
+	limit := ctx.Int(historyLimitFlag.Name)
+	if limit < 1 {
+		return fmt.Errorf("--%s has to be positive, have %d", historyLimitFlag.Name, limit)
+	}
+	state.SetHistoryLimit(limit)
+
+	eras, err := loadEras(ctx)
+	if err != nil {
+		return err
+	}
+	if eras != nil {
+		state.SetEras(eras)
+	}
+
+	timestamps, err := loadTimestamps(ctx)
+	if err != nil {
+		return err
+	}
+
+	failOnViolation, err := validationPolicy(ctx)
+	if err != nil {
+		return err
+	}
+	validator := validate.New()
+
+	progress, err := newReplayProgress(supplyFilePath, lastParsedFile, ctx.Bool(replayLiveFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	eventsCh, err := reader.ReadFiles(supplyFilePath, lastParsedFile, ctx.Bool(replayLiveFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	interval := ctx.Duration(replayProgressFlag.Name)
+	lastReport := time.Now()
+
+	entryErrCh := make(chan error, 16)
+	for event := range eventsCh {
+		switch event.Kind {
+		case reader.EventBlock:
+			fillTimestamp(&event.Supply, timestamps)
+			if violations := validator.Check(event.Supply, event.Pos); failOnViolation && hasError(violations) {
+				return fmt.Errorf("rejecting block %d entry, it failed validation\n\tat %s", event.Supply.Number, event.Pos)
+			}
+
+			state.HandleEntry(event.Supply, entryErrCh)
+			if len(entryErrCh) > 0 {
+				return fmt.Errorf("%v\n\tat %s", <-entryErrCh, event.Pos)
+			}
+
+			progress.block(event.Pos.Offset)
+		case reader.EventFileStart:
+			progress.fileStart()
+		case reader.EventFileEnd:
+			progress.fileEnd(event.Pos.Offset)
+		case reader.EventCheckpoint:
+			lastParsedFile = filepath.Base(event.Pos.File)
+			state.SaveState(stateFilePath, lastParsedFile)
+		case reader.EventError:
+			return fmt.Errorf("%v\n\tat %s", event.Err, event.Pos)
+		}
+
+		if interval > 0 && time.Since(lastReport) >= interval {
+			log.Println(progress)
+			lastReport = time.Now()
+		}
+	}
+
+	log.Println(progress)
+	log.Printf("Replayed to block %d (%s), the state is saved in '%s' with last parsed file '%s'", state.BlockNumber, state.Hash, stateFilePath, lastParsedFile)
+
+	return nil
+}
+
+// replayProgress tracks the progress of a replay by the bytes read of the files to replay
+type replayProgress struct {
+	start time.Time
+
+	totalFiles int
+	totalBytes int64
+
+	files     int   // Files started
+	doneBytes int64 // Bytes of the finished files
+	offset    int64 // Offset of the last block of the current file
+	blocks    int
+}
+
+// newReplayProgress returns the progress of replaying the files of path after skipUntilFile
+func newReplayProgress(path, skipUntilFile string, live bool) (*replayProgress, error) {
+	dir, file := filepath.Split(path)
+	files, err := reader.FindAndSortLogFiles(dir, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list and sort log files: %v", err)
+	}
+
+	p := &replayProgress{start: time.Now()}
+
+	skipping := skipUntilFile != ""
+	for _, name := range files {
+		if skipping {
+			skipping = name != skipUntilFile
+			continue
+		}
+		if !live && name == file {
+			continue
+		}
+
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		p.totalFiles++
+		p.totalBytes += info.Size()
+	}
+
+	return p, nil
+}
+
+func (p *replayProgress) fileStart() {
+	p.files++
+	p.offset = 0
+}
+
+func (p *replayProgress) fileEnd(offset int64) {
+	p.doneBytes += offset
+	p.offset = 0
+}
+
+func (p *replayProgress) block(offset int64) {
+	p.blocks++
+	p.offset = offset
+}
+
+func (p *replayProgress) String() string {
+	elapsed := time.Since(p.start)
+	read := p.doneBytes + p.offset
+
+	percent := 100.0
+	if p.totalBytes > 0 {
+		percent = float64(read) / float64(p.totalBytes) * 100
+	}
+
+	var blocksPerSec float64
+	eta := "unknown"
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		blocksPerSec = float64(p.blocks) / seconds
+		if read > 0 {
+			remaining := float64(p.totalBytes-read) / (float64(read) / seconds)
+			eta = (time.Duration(remaining) * time.Second).String()
+		}
+	}
+
+	return fmt.Sprintf("Replayed %d/%d files, %d/%d bytes (%.1f%%), %d blocks, %.0f blocks/s, elapsed %s, ETA %s",
+		p.files, p.totalFiles, read, p.totalBytes, percent, p.blocks, blocksPerSec, elapsed.Round(time.Second), eta)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+func runReplay(t *testing.T, args ...string) {
+	t.Helper()
+
+	app := &cli.App{Commands: []*cli.Command{replayCommand}}
+	if err := app.Run(append([]string{"supply-tracer-parser", "replay"}, args...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	lines := strings.SplitAfter(exportTestSupply, "\n")
+
+	// Blocks 0-1 in a rotated file, and the canonical blocks 2-3 in the live file
+	if err := os.WriteFile(filepath.Join(dir, "supply-1.jsonl"), []byte(strings.Join(lines[:2], "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "supply.jsonl"), []byte(strings.Join(lines[3:], "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	statePath := filepath.Join(dir, "state.json")
+
+	// The live file is skipped by default
+	runReplay(t, "--supply.file", filepath.Join(dir, "supply.jsonl"), "--state.file", statePath, "--progress", "0")
+
+	state := tracker.NewState()
+	file, err := state.LoadState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != "supply-1.jsonl" || state.BlockNumber != 1 || state.Delta.Int64() != 18 {
+		t.Errorf("unexpected state: file %s, block %d, delta %s", file, state.BlockNumber, state.Delta)
+	}
+
+	// Resuming reads the live file after the last parsed one
+	runReplay(t, "--supply.file", filepath.Join(dir, "supply.jsonl"), "--state.file", statePath, "--resume", "--live")
+
+	state = tracker.NewState()
+	if file, err = state.LoadState(statePath); err != nil {
+		t.Fatal(err)
+	}
+	if file != "supply.jsonl" || state.BlockNumber != 3 || state.Delta.Int64() != 23 {
+		t.Errorf("unexpected resumed state: file %s, block %d, delta %s", file, state.BlockNumber, state.Delta)
+	}
+}
@@ -0,0 +1,235 @@
+package supply
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Category is an issuance or burn component, by its JSON name
+type Category struct {
+	Name   string
+	Amount *big.Int
+	Known  bool // Whether the category is known to this version
+}
+
+// Categories returns the issuance components, the known ones first
+// and then the unknown ones sorted by name
+func (i *Issuance) Categories() []Category {
+	if i == nil {
+		return nil
+	}
+	cs := []Category{
+		{"genesisAlloc", i.GenesisAlloc, true},
+		{"reward", i.Reward, true},
+		{"withdrawals", i.Withdrawals, true},
+	}
+
+	return append(cs, otherCategories(i.Other)...)
+}
+
+// Categories returns the burn components, the known ones first
+// and then the unknown ones sorted by name
+func (b *Burn) Categories() []Category {
+	if b == nil {
+		return nil
+	}
+	cs := []Category{
+		{"eip1559", b.EIP1559, true},
+		{"blob", b.Blob, true},
+		{"misc", b.Misc, true},
+	}
+
+	return append(cs, otherCategories(b.Other)...)
+}
+
+// AddTo adds the issuance components to dst, or subtracts them when sub is set,
+// and returns dst. It does not allocate once dst has grown to the size of the sum.
+func (i *Issuance) AddTo(dst *big.Int, sub bool) *big.Int {
+	if i == nil {
+		return dst
+	}
+	addBig(dst, i.GenesisAlloc, sub)
+	addBig(dst, i.Reward, sub)
+	addBig(dst, i.Withdrawals, sub)
+	for _, amount := range i.Other {
+		addBig(dst, amount, sub)
+	}
+
+	return dst
+}
+
+// AddTo adds the burn components to dst, or subtracts them when sub is set,
+// and returns dst. It does not allocate once dst has grown to the size of the sum.
+func (b *Burn) AddTo(dst *big.Int, sub bool) *big.Int {
+	if b == nil {
+		return dst
+	}
+	addBig(dst, b.EIP1559, sub)
+	addBig(dst, b.Blob, sub)
+	addBig(dst, b.Misc, sub)
+	for _, amount := range b.Other {
+		addBig(dst, amount, sub)
+	}
+
+	return dst
+}
+
+func addBig(dst, n *big.Int, sub bool) {
+	if n == nil {
+		return
+	}
+	if sub {
+		dst.Sub(dst, n)
+	} else {
+		dst.Add(dst, n)
+	}
+}
+
+func otherCategories(other map[string]*big.Int) []Category {
+	names := make([]string, 0, len(other))
+	for name := range other {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cs := make([]Category, 0, len(names))
+	for _, name := range names {
+		cs = append(cs, Category{Name: name, Amount: other[name]})
+	}
+
+	return cs
+}
+
+// MarshalJSON marshals as JSON, including the unknown categories.
+func (i Issuance) MarshalJSON() ([]byte, error) {
+	return marshalCategories(i.Categories())
+}
+
+// UnmarshalJSON unmarshals from JSON, keeping the unknown categories in Other.
+func (i *Issuance) UnmarshalJSON(input []byte) error {
+	other, err := unmarshalCategories(input, []knownCategory{
+		{"genesisAlloc", &i.GenesisAlloc},
+		{"reward", &i.Reward},
+		{"withdrawals", &i.Withdrawals},
+	})
+	if err != nil {
+		return err
+	}
+	i.Other = other
+
+	return nil
+}
+
+// MarshalJSON marshals as JSON, including the unknown categories.
+func (b Burn) MarshalJSON() ([]byte, error) {
+	return marshalCategories(b.Categories())
+}
+
+// UnmarshalJSON unmarshals from JSON, keeping the unknown categories in Other.
+func (b *Burn) UnmarshalJSON(input []byte) error {
+	other, err := unmarshalCategories(input, []knownCategory{
+		{"eip1559", &b.EIP1559},
+		{"blob", &b.Blob},
+		{"misc", &b.Misc},
+	})
+	if err != nil {
+		return err
+	}
+	b.Other = other
+
+	return nil
+}
+
+// marshalCategories marshals the categories as hex big integers, omitting the nil ones
+func marshalCategories(cs []Category) ([]byte, error) {
+	enc := make(map[string]*hexutil.Big, len(cs))
+	for _, c := range cs {
+		if c.Amount != nil {
+			enc[c.Name] = (*hexutil.Big)(c.Amount)
+		}
+	}
+
+	return json.Marshal(enc)
+}
+
+// knownCategory is a known category and its field
+type knownCategory struct {
+	name  string
+	field **big.Int
+}
+
+// unmarshalCategories unmarshals the known categories into their fields,
+// and returns the unknown ones. The amounts are parsed as signed, so that
+// negative components reach the validation instead of failing the decoding.
+func unmarshalCategories(input []byte, known []knownCategory) (map[string]*big.Int, error) {
+	var dec map[string]json.RawMessage
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return nil, err
+	}
+
+	var other map[string]*big.Int
+	for name, raw := range dec {
+		if string(raw) == "null" {
+			continue
+		}
+		amount, err := ParseSignedBig(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", name, err)
+		}
+		if field := knownField(known, name); field != nil {
+			*field = amount
+			continue
+		}
+		if other == nil {
+			other = make(map[string]*big.Int)
+		}
+		other[name] = amount
+	}
+
+	return other, nil
+}
+
+func knownField(known []knownCategory, name string) **big.Int {
+	for _, c := range known {
+		if c.name == name {
+			return c.field
+		}
+	}
+	return nil
+}
+
+// AddOther adds the unknown categories of src to dst, or subtracts them when sub is set,
+// and returns dst, which is allocated when nil
+func AddOther(dst, src map[string]*big.Int, sub bool) map[string]*big.Int {
+	for name, amount := range src {
+		if dst == nil {
+			dst = make(map[string]*big.Int)
+		}
+		if dst[name] == nil {
+			dst[name] = new(big.Int)
+		}
+		if sub {
+			dst[name].Sub(dst[name], amount)
+		} else {
+			dst[name].Add(dst[name], amount)
+		}
+	}
+
+	return dst
+}
+
+func copyOther(other map[string]*big.Int) map[string]*big.Int {
+	if other == nil {
+		return nil
+	}
+	cpy := make(map[string]*big.Int, len(other))
+	for name, amount := range other {
+		cpy[name] = copyBig(amount)
+	}
+
+	return cpy
+}
@@ -0,0 +1,380 @@
+// Package supply contains the types of the supply data
+// as generated by the supply tracer of go-ethereum.
+package supply
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"strconv"
+
+	"github.com/buger/jsonparser"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/holiman/uint256"
+)
+
+// Issuance holds the issuance components of the supply data
+type Issuance struct {
+	GenesisAlloc *big.Int `json:"genesisAlloc,omitempty"`
+	Reward       *big.Int `json:"reward,omitempty"`
+	Withdrawals  *big.Int `json:"withdrawals,omitempty"`
+
+	// Other holds the categories unknown to this version, by their JSON name
+	Other map[string]*big.Int `json:"-"`
+}
+
+// Burn holds the burn components of the supply data
+type Burn struct {
+	EIP1559 *big.Int `json:"eip1559,omitempty"`
+	Blob    *big.Int `json:"blob,omitempty"`
+	Misc    *big.Int `json:"misc,omitempty"`
+
+	// Other holds the categories unknown to this version, by their JSON name
+	Other map[string]*big.Int `json:"-"`
+}
+
+// Info is the structure of the supply data
+// as generated by the supply tracer at go-ethereum
+type Info struct {
+	Delta    *big.Int  `json:"delta"` // Calculated from the issuance and burn components
+	Issuance *Issuance `json:"issuance,omitempty"`
+	Burn     *Burn     `json:"burn,omitempty"`
+
+	// ReportedDelta is the delta as written by the tracer, nil if it was not written
+	ReportedDelta *big.Int `json:"-"`
+
+	// Block info
+	Number     uint64      `json:"blockNumber"`
+	Hash       common.Hash `json:"hash"`
+	ParentHash common.Hash `json:"parentHash"`
+	Timestamp  uint64      `json:"timestamp,omitempty"` // Unix time of the block, zero if unknown
+}
+
+// New returns supply data with all components set to zero
+func New() Info {
+	return Info{
+		Delta: big.NewInt(0),
+		Issuance: &Issuance{
+			GenesisAlloc: big.NewInt(0),
+			Reward:       big.NewInt(0),
+			Withdrawals:  big.NewInt(0),
+		},
+		Burn: &Burn{
+			EIP1559: big.NewInt(0),
+			Blob:    big.NewInt(0),
+			Misc:    big.NewInt(0),
+		},
+	}
+}
+
+// Copy returns a deep copy of the issuance components
+func (i *Issuance) Copy() *Issuance {
+	if i == nil {
+		return nil
+	}
+	return &Issuance{
+		GenesisAlloc: copyBig(i.GenesisAlloc),
+		Reward:       copyBig(i.Reward),
+		Withdrawals:  copyBig(i.Withdrawals),
+		Other:        copyOther(i.Other),
+	}
+}
+
+// Copy returns a deep copy of the burn components
+func (b *Burn) Copy() *Burn {
+	if b == nil {
+		return nil
+	}
+	return &Burn{
+		EIP1559: copyBig(b.EIP1559),
+		Blob:    copyBig(b.Blob),
+		Misc:    copyBig(b.Misc),
+		Other:   copyOther(b.Other),
+	}
+}
+
+// ParseSignedBig parses a JSON number, or a JSON string holding an optionally signed
+// decimal or hex number of up to 256 bits, e.g. "-0x1". Hex numbers need the 0x prefix.
+func ParseSignedBig(input json.RawMessage) (*big.Int, error) {
+	n := new(big.Int)
+	if err := setSignedBig(n, input); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// setSignedBig sets z to the number of the input as ParseSignedBig parses it,
+// reusing the words of z
+func setSignedBig(z *big.Int, input json.RawMessage) error {
+	text := []byte(input)
+	if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+		text = text[1 : len(text)-1]
+		if bytes.IndexByte(text, '\\') >= 0 {
+			var str string
+			if err := json.Unmarshal(input, &str); err != nil {
+				return fmt.Errorf("cannot parse %s as a number", input)
+			}
+			text = []byte(str)
+		}
+	}
+
+	negative := len(text) > 0 && text[0] == '-'
+	if len(text) > 0 && (text[0] == '-' || text[0] == '+') {
+		text = text[1:]
+	}
+
+	var (
+		u   uint256.Int
+		err error
+	)
+	switch {
+	case len(text) > 2 && text[0] == '0' && (text[1] == 'x' || text[1] == 'X') && isDigits(text[2:], 16):
+		err = u.SetFromHex(string(text))
+	case len(text) > 0 && isDigits(text, 10):
+		err = u.SetFromDecimal(string(text))
+	default:
+		return fmt.Errorf("cannot parse %s as a number", input)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot parse %s as a number: %v", input, err)
+	}
+
+	words := z.Bits()[:0]
+	for _, word := range u {
+		words = append(words, big.Word(word))
+		if bits.UintSize == 32 {
+			words = append(words, big.Word(word>>32))
+		}
+	}
+	z.SetBits(words)
+	if negative {
+		z.Neg(z)
+	}
+
+	return nil
+}
+
+// isDigits returns whether the text only has digits of the base, 10 or 16
+func isDigits(text []byte, base int) bool {
+	for _, c := range text {
+		switch {
+		case c >= '0' && c <= '9':
+		case base == 16 && (c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func copyBig(n *big.Int) *big.Int {
+	if n == nil {
+		return nil
+	}
+	return new(big.Int).Set(n)
+}
+
+// CalculatedDelta calculates the supply delta
+func (s *Info) CalculatedDelta() *big.Int {
+	return s.AddDeltaTo(new(big.Int), false)
+}
+
+// AddDeltaTo adds the calculated supply delta to dst, or subtracts it when sub is set,
+// and returns dst. Unlike CalculatedDelta, it does not allocate once dst has grown to the size of the sum.
+func (s *Info) AddDeltaTo(dst *big.Int, sub bool) *big.Int {
+	s.Issuance.AddTo(dst, sub)
+	s.Burn.AddTo(dst, !sub)
+
+	return dst
+}
+
+// entryInts are the integers of a decoded entry: the six known components, the delta and the reported delta
+const entryInts = 8
+
+// entryWords is the number of words of each integer of a decoded entry, enough for 256 bits
+// and the carry of an addition, so that summing the components does not grow them
+const entryWords = 256/bits.UintSize + 1
+
+// entryStorage holds the components of a decoded entry, so that they are allocated at once
+type entryStorage struct {
+	issuance Issuance
+	burn     Burn
+	ints     [entryInts]big.Int
+	words    [entryInts * entryWords]big.Word
+}
+
+// newEntryStorage returns the components of an entry, set to zero,
+// with the words of each integer preallocated
+func newEntryStorage() *entryStorage {
+	st := new(entryStorage)
+	for i := range st.ints {
+		st.ints[i].SetBits(st.words[i*entryWords : i*entryWords : (i+1)*entryWords])
+	}
+	st.issuance = Issuance{GenesisAlloc: &st.ints[0], Reward: &st.ints[1], Withdrawals: &st.ints[2]}
+	st.burn = Burn{EIP1559: &st.ints[3], Blob: &st.ints[4], Misc: &st.ints[5]}
+
+	return st
+}
+
+// UnmarshalJSON unmarshals from JSON.
+// Missing components default to zero and the delta is recalculated,
+// while the written delta is kept as ReportedDelta.
+// The known components and the deltas are allocated at once, and decoded in place.
+func (s *Info) UnmarshalJSON(input []byte) error {
+	*s = Info{}
+	st := newEntryStorage()
+
+	type Alias Info
+	dec := struct {
+		*Alias
+		Delta     amountField     `json:"delta"`
+		Timestamp timestampField  `json:"timestamp"`
+		Issuance  categoriesField `json:"issuance"`
+		Burn      categoriesField `json:"burn"`
+	}{
+		Alias:    (*Alias)(s),
+		Delta:    amountField{amount: &st.ints[7]},
+		Issuance: categoriesField{known: &st.issuance},
+		Burn:     categoriesField{known: &st.burn},
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+
+	s.Issuance, s.Burn, s.Delta = &st.issuance, &st.burn, &st.ints[6]
+	s.Issuance.Other, s.Burn.Other = dec.Issuance.other, dec.Burn.other
+	s.Timestamp = uint64(dec.Timestamp)
+	if dec.Delta.set {
+		s.ReportedDelta = dec.Delta.amount
+	}
+	s.AddDeltaTo(s.Delta, false)
+
+	return nil
+}
+
+// field returns the known component of the category, nil if it is unknown
+func (i *Issuance) field(name []byte) *big.Int {
+	switch string(name) {
+	case "genesisAlloc":
+		return i.GenesisAlloc
+	case "reward":
+		return i.Reward
+	case "withdrawals":
+		return i.Withdrawals
+	}
+	return nil
+}
+
+// field returns the known component of the category, nil if it is unknown
+func (b *Burn) field(name []byte) *big.Int {
+	switch string(name) {
+	case "eip1559":
+		return b.EIP1559
+	case "blob":
+		return b.Blob
+	case "misc":
+		return b.Misc
+	}
+	return nil
+}
+
+// amountField decodes a signed amount into its preallocated integer
+type amountField struct {
+	amount *big.Int
+	set    bool // Whether the amount was written
+}
+
+func (a *amountField) UnmarshalJSON(input []byte) error {
+	if string(input) == "null" {
+		return nil
+	}
+	if err := setSignedBig(a.amount, input); err != nil {
+		return err
+	}
+	a.set = true
+
+	return nil
+}
+
+// timestampField decodes a timestamp written as a number, or as a hex string by newer tracers
+type timestampField uint64
+
+func (t *timestampField) UnmarshalJSON(input []byte) error {
+	if string(input) == "null" {
+		return nil
+	}
+
+	var (
+		timestamp uint64
+		err       error
+	)
+	if len(input) >= 2 && input[0] == '"' {
+		timestamp, err = hexutil.DecodeUint64(string(bytes.Trim(input, `"`)))
+	} else {
+		timestamp, err = strconv.ParseUint(string(input), 10, 64)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %s", input)
+	}
+	*t = timestampField(timestamp)
+
+	return nil
+}
+
+// categoriesField decodes the amounts of the categories as signed,
+// into their known component or into the unknown categories
+type categoriesField struct {
+	known interface{ field(name []byte) *big.Int }
+	other map[string]*big.Int
+}
+
+func (c *categoriesField) UnmarshalJSON(input []byte) error {
+	if string(input) == "null" {
+		return nil
+	}
+
+	return jsonparser.ObjectEach(input, func(key, value []byte, dataType jsonparser.ValueType, _ int) error {
+		if bytes.IndexByte(key, '\\') >= 0 {
+			name, err := jsonparser.ParseString(key)
+			if err != nil {
+				return err
+			}
+			key = []byte(name)
+		}
+
+		switch dataType {
+		case jsonparser.Null:
+			return nil
+		case jsonparser.String:
+			if bytes.IndexByte(value, '\\') >= 0 {
+				str, err := jsonparser.ParseString(value)
+				if err != nil {
+					return err
+				}
+				value = []byte(str)
+			}
+		case jsonparser.Number:
+		default:
+			return fmt.Errorf("invalid %s: cannot parse %s as a number", key, value)
+		}
+
+		amount := c.known.field(key)
+		if amount == nil {
+			if c.other == nil {
+				c.other = make(map[string]*big.Int)
+			}
+			amount = new(big.Int)
+			c.other[string(key)] = amount
+		}
+		if err := setSignedBig(amount, value); err != nil {
+			return fmt.Errorf("invalid %s: %v", key, err)
+		}
+
+		return nil
+	})
+}
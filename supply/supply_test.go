@@ -0,0 +1,282 @@
+package supply
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestUnmarshalJSONDefaults(t *testing.T) {
+	var s Info
+	if err := json.Unmarshal([]byte(`{"blockNumber":1,"issuance":{"reward":"0x3"},"burn":{"eip1559":"0x1"}}`), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Issuance.GenesisAlloc.Sign() != 0 || s.Issuance.Withdrawals.Sign() != 0 || s.Burn.Blob.Sign() != 0 || s.Burn.Misc.Sign() != 0 {
+		t.Errorf("missing components not defaulted to zero")
+	}
+	if s.Delta.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("delta want 2, have %s", s.Delta)
+	}
+	if s.ReportedDelta != nil {
+		t.Errorf("reported delta want nil, have %s", s.ReportedDelta)
+	}
+}
+
+func TestUnmarshalJSONReportedDelta(t *testing.T) {
+	tests := []struct {
+		delta string
+		want  int64
+	}{
+		{`"0x2"`, 2},
+		{`"-0x2"`, -2},
+		{`"-2"`, -2},
+		{`2`, 2},
+		{`-2`, -2},
+	}
+
+	for _, tt := range tests {
+		var s Info
+		input := `{"blockNumber":1,"delta":` + tt.delta + `,"issuance":{"reward":"0x1"}}`
+		if err := json.Unmarshal([]byte(input), &s); err != nil {
+			t.Errorf("delta %s: %v", tt.delta, err)
+			continue
+		}
+		if s.ReportedDelta == nil || s.ReportedDelta.Int64() != tt.want {
+			t.Errorf("delta %s: want reported %d, have %v", tt.delta, tt.want, s.ReportedDelta)
+		}
+
+		// The delta is always calculated from the components
+		if s.Delta.Int64() != 1 {
+			t.Errorf("delta %s: want calculated 1, have %s", tt.delta, s.Delta)
+		}
+	}
+
+	var s Info
+	if err := json.Unmarshal([]byte(`{"delta":"0xzz"}`), &s); err == nil {
+		t.Errorf("expected error for invalid delta")
+	}
+}
+
+func TestUnknownCategories(t *testing.T) {
+	var s Info
+	input := `{"blockNumber":1,"issuance":{"reward":"0x3","newIssuance":"0x2"},"burn":{"eip1559":"0x1","systemContract":"0x4"}}`
+	if err := json.Unmarshal([]byte(input), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Issuance.Other["newIssuance"].Int64() != 2 || s.Burn.Other["systemContract"].Int64() != 4 {
+		t.Errorf("unknown categories not kept: %v %v", s.Issuance.Other, s.Burn.Other)
+	}
+	if s.Delta.Int64() != 0 {
+		t.Errorf("delta want 0, have %s", s.Delta)
+	}
+
+	// Unknown categories are marshalled back
+	out, err := json.Marshal(s.Burn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var burn Burn
+	if err := json.Unmarshal(out, &burn); err != nil {
+		t.Fatal(err)
+	}
+	if burn.EIP1559.Int64() != 1 || burn.Other["systemContract"].Int64() != 4 {
+		t.Errorf("unexpected marshalled burn %s", out)
+	}
+}
+
+func TestAddDeltaTo(t *testing.T) {
+	var s Info
+	input := `{"blockNumber":1,"issuance":{"reward":"0x5","newIssuance":"0x2"},"burn":{"eip1559":"0x1","systemContract":"0x4"}}`
+	if err := json.Unmarshal([]byte(input), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := big.NewInt(10)
+	if s.AddDeltaTo(dst, false); dst.Int64() != 12 {
+		t.Errorf("add: want 12, have %s", dst)
+	}
+	if s.AddDeltaTo(dst, true); dst.Int64() != 10 {
+		t.Errorf("sub: want 10, have %s", dst)
+	}
+	if s.Burn.AddTo(dst, true); dst.Int64() != 5 {
+		t.Errorf("sub burn: want 5, have %s", dst)
+	}
+
+	// The missing components are zero, and not shared with other entries
+	var other Info
+	if err := json.Unmarshal([]byte(input), &other); err != nil {
+		t.Fatal(err)
+	}
+	other.Issuance.GenesisAlloc.SetInt64(1)
+	if s.Issuance.GenesisAlloc.Sign() != 0 || s.Burn.Misc.Sign() != 0 {
+		t.Errorf("missing components are shared between entries")
+	}
+}
+
+func TestUnmarshalJSONTimestamp(t *testing.T) {
+	for _, timestamp := range []string{`"0x6436fff7"`, `1681326071`} {
+		var s Info
+		if err := json.Unmarshal([]byte(`{"blockNumber":1,"timestamp":`+timestamp+`}`), &s); err != nil {
+			t.Errorf("timestamp %s: %v", timestamp, err)
+			continue
+		}
+		if s.Timestamp != 1681326071 {
+			t.Errorf("timestamp %s: want 1681326071, have %d", timestamp, s.Timestamp)
+		}
+	}
+
+	var s Info
+	if err := json.Unmarshal([]byte(`{"timestamp":"-0x1"}`), &s); err == nil {
+		t.Errorf("expected error for negative timestamp")
+	}
+}
+
+func TestParseSignedBig(t *testing.T) {
+	for input, want := range map[string]int64{
+		`"010"`:    10,
+		`10`:       10,
+		`-10`:      -10,
+		`"+10"`:    10,
+		`"0x1f"`:   31,
+		`"-0x1"`:   -1,
+		`"0X0"`:    0,
+		`"\u0031"`: 1,
+	} {
+		n, err := ParseSignedBig([]byte(input))
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if n.Int64() != want {
+			t.Errorf("%s: want %d, have %s", input, want, n)
+		}
+	}
+
+	for _, input := range []string{`""`, `"-"`, `"0x"`, `"1_000"`, `"0b1"`, `"0o7"`, `"--1"`, `"+-1"`, `"0x01"`, `"0xg"`, `"1e3"`, `1.5`, `" 1"`} {
+		if n, err := ParseSignedBig([]byte(input)); err == nil {
+			t.Errorf("%s: expected error, have %s", input, n)
+		}
+	}
+}
+
+func TestUnmarshalJSONComponents(t *testing.T) {
+	var s Info
+	if err := json.Unmarshal([]byte(`{"blockNumber":1,"delta":"0x5","issuance":{"rew\u0061rd":"0x3","newIssuance":"0x4"},"burn":{"misc":"-0x1"}}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Issuance.Reward.Int64() != 3 || s.Issuance.Withdrawals.Sign() != 0 || s.Burn.EIP1559.Sign() != 0 || s.Burn.Misc.Int64() != -1 {
+		t.Errorf("unexpected components %s %s %s %s", s.Issuance.Reward, s.Issuance.Withdrawals, s.Burn.EIP1559, s.Burn.Misc)
+	}
+	if len(s.Issuance.Other) != 1 || s.Issuance.Other["newIssuance"].Int64() != 4 {
+		t.Errorf("unexpected unknown categories %v", s.Issuance.Other)
+	}
+	if s.ReportedDelta.Int64() != 5 || s.Delta.Int64() != 8 {
+		t.Errorf("want delta 8 and reported delta 5, have %s and %s", s.Delta, s.ReportedDelta)
+	}
+
+	for _, line := range []string{
+		`{"blockNumber":3,"issuance":{"reward":"0xz"}}`,
+		`{"blockNumber":3}{`,
+	} {
+		if err := json.Unmarshal([]byte(line), &s); err == nil {
+			t.Errorf("expected error for %s", line)
+		}
+	}
+}
+
+const benchLine = `{"blockNumber":19000000,"hash":"0x2f5a9d4d4a4a7b5e0c2e0f1b3f4a8c2d9e6b1a0c3d5e7f9a1b2c3d4e5f6a7b8c","parentHash":"0x1e4a8c3c3939a6a4d1b1e0a2e39b7b1c8d5a0f9b2c4d6e8f0a1b2c3d4e5f6a7b","timestamp":"0x65a8b2c3","delta":"0x1bc16d674ec80000","issuance":{"reward":"0x1bc16d674ec80000","withdrawals":"0x8ac7230489e80000"},"burn":{"eip1559":"0x8ac7230489e80000"}}`
+
+// reflectInfo decodes a line with encoding/json and hexutil into separately allocated integers,
+// as the lines were decoded before. It is the baseline of BenchmarkUnmarshalInfo.
+type reflectInfo struct {
+	Delta      *hexutil.Big            `json:"delta"`
+	Issuance   map[string]*hexutil.Big `json:"issuance"`
+	Burn       map[string]*hexutil.Big `json:"burn"`
+	Number     uint64                  `json:"blockNumber"`
+	Hash       common.Hash             `json:"hash"`
+	ParentHash common.Hash             `json:"parentHash"`
+	Timestamp  hexutil.Uint64          `json:"timestamp"`
+}
+
+// BenchmarkUnmarshalInfo compares the decoding of a line into separately allocated integers
+// with UnmarshalJSON, which allocates the components at once:
+//
+//	go test ./supply -run xxx -bench UnmarshalInfo -benchmem
+func BenchmarkUnmarshalInfo(b *testing.B) {
+	line := []byte(benchLine)
+
+	b.Run("reflect", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var s reflectInfo
+			if err := json.Unmarshal(line, &s); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("UnmarshalJSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var s Info
+			if err := s.UnmarshalJSON(line); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCalculatedDelta(b *testing.B) {
+	var s Info
+	if err := json.Unmarshal([]byte(benchLine), &s); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.CalculatedDelta()
+	}
+}
+
+// BenchmarkSums compares the sums of the components of a line into new integers,
+// as the totals were accumulated before AddTo, with the sums into reused integers:
+//
+//	go test ./supply -run xxx -bench Sums -benchmem
+func BenchmarkSums(b *testing.B) {
+	var s Info
+	if err := s.UnmarshalJSON([]byte(benchLine)); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("categories", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			issuance, burn := new(big.Int), new(big.Int)
+			for _, c := range s.Issuance.Categories() {
+				if c.Amount != nil {
+					issuance.Add(issuance, c.Amount)
+				}
+			}
+			for _, c := range s.Burn.Categories() {
+				if c.Amount != nil {
+					burn.Add(burn, c.Amount)
+				}
+			}
+			s.CalculatedDelta()
+		}
+	})
+	b.Run("AddTo", func(b *testing.B) {
+		issuance, burn, delta := new(big.Int), new(big.Int), new(big.Int)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s.Issuance.AddTo(issuance, false)
+			s.Burn.AddTo(burn, false)
+			s.AddDeltaTo(delta, false)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package supply
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Unit is a denomination of ether
+type Unit struct {
+	Name     string
+	Decimals int // Number of decimals of the unit in wei
+}
+
+var (
+	Wei   = Unit{Name: "wei", Decimals: 0}
+	Gwei  = Unit{Name: "gwei", Decimals: 9}
+	Ether = Unit{Name: "eth", Decimals: 18}
+)
+
+// ParseUnit returns the unit with the given name
+func ParseUnit(name string) (Unit, error) {
+	switch strings.ToLower(name) {
+	case Wei.Name:
+		return Wei, nil
+	case Gwei.Name:
+		return Gwei, nil
+	case Ether.Name, "ether":
+		return Ether, nil
+	default:
+		return Unit{}, fmt.Errorf("unknown unit %q", name)
+	}
+}
+
+// FormatUnits formats an amount in wei as a signed decimal string of the unit,
+// with all the decimals of the unit, e.g. "-1.500000000" gwei
+func FormatUnits(amount *big.Int, unit Unit) string {
+	if amount == nil {
+		amount = new(big.Int)
+	}
+
+	digits := new(big.Int).Abs(amount).String()
+
+	sign := ""
+	if amount.Sign() < 0 {
+		sign = "-"
+	}
+	if unit.Decimals == 0 {
+		return sign + digits
+	}
+
+	// Pad with zeros, so that there is at least one integer digit
+	if len(digits) <= unit.Decimals {
+		digits = strings.Repeat("0", unit.Decimals-len(digits)+1) + digits
+	}
+	point := len(digits) - unit.Decimals
+
+	return sign + digits[:point] + "." + digits[point:]
+}
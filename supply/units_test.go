@@ -0,0 +1,42 @@
+package supply
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFormatUnits(t *testing.T) {
+	oneEther, _ := new(big.Int).SetString("1000000000000000000", 10)
+
+	tests := []struct {
+		amount *big.Int
+		unit   Unit
+		want   string
+	}{
+		{big.NewInt(0), Wei, "0"},
+		{big.NewInt(-15), Wei, "-15"},
+		{big.NewInt(1), Gwei, "0.000000001"},
+		{big.NewInt(-1500000000), Gwei, "-1.500000000"},
+		{oneEther, Ether, "1.000000000000000000"},
+		{new(big.Int).Neg(oneEther), Ether, "-1.000000000000000000"},
+		{big.NewInt(1), Ether, "0.000000000000000001"},
+		{nil, Ether, "0.000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		if have := FormatUnits(tt.amount, tt.unit); have != tt.want {
+			t.Errorf("FormatUnits(%s, %s): want %s, have %s", tt.amount, tt.unit.Name, tt.want, have)
+		}
+	}
+}
+
+func TestParseUnit(t *testing.T) {
+	for name, want := range map[string]Unit{"wei": Wei, "GWEI": Gwei, "eth": Ether, "ether": Ether} {
+		if have, err := ParseUnit(name); err != nil || have != want {
+			t.Errorf("ParseUnit(%s): want %v, have %v (%v)", name, want, have, err)
+		}
+	}
+	if _, err := ParseUnit("finney"); err == nil {
+		t.Errorf("expected error for unknown unit")
+	}
+}
@@ -0,0 +1,250 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+// Era is a range of blocks starting at a fork boundary, up to the next era
+type Era struct {
+	Name string `json:"name"`
+	From uint64 `json:"from"` // First block of the era
+}
+
+// NetworkEras are the eras of the known networks, at the forks that changed the issuance or burn
+var NetworkEras = map[string][]Era{
+	"mainnet": {
+		{"frontier", 0},
+		{"homestead", 1_150_000},
+		{"byzantium", 4_370_000},
+		{"constantinople", 7_280_000},
+		{"london", 12_965_000},
+		{"paris", 15_537_394},
+		{"shanghai", 17_034_870},
+		{"cancun", 19_426_587},
+		{"prague", 22_431_084},
+	},
+	"sepolia": {
+		{"london", 0},
+		{"paris", 1_450_409},
+		{"shanghai", 2_990_908},
+		{"cancun", 5_187_023},
+		{"prague", 7_836_331},
+	},
+}
+
+// ParseEras parses eras in the format "name=block,name=block", e.g. "paris=15537394,shanghai=17034870".
+// The eras are sorted by their first block.
+func ParseEras(spec string) ([]Era, error) {
+	var eras []Era
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, block, ok := strings.Cut(part, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid era %q, want name=block", part)
+		}
+		from, err := strconv.ParseUint(block, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block of era %q: %v", name, err)
+		}
+		eras = append(eras, Era{Name: name, From: from})
+	}
+	if len(eras) == 0 {
+		return nil, fmt.Errorf("no eras in %q", spec)
+	}
+
+	sort.SliceStable(eras, func(i, j int) bool { return eras[i].From < eras[j].From })
+	for i := 1; i < len(eras); i++ {
+		if eras[i].From == eras[i-1].From {
+			return nil, fmt.Errorf("eras %s and %s start at the same block %d", eras[i-1].Name, eras[i].Name, eras[i].From)
+		}
+	}
+
+	return eras, nil
+}
+
+// EraTotal is the supply accounted within an era
+type EraTotal struct {
+	Era
+	Since    uint64           `json:"since,omitempty"` // First block accounted, when the eras were set after the start of the era
+	Delta    *big.Int         `json:"delta"`
+	Issuance *supply.Issuance `json:"issuance"`
+	Burn     *supply.Burn     `json:"burn"`
+}
+
+func newEraTotal(era Era) *EraTotal {
+	entry := supply.New()
+
+	return &EraTotal{
+		Era:      era,
+		Delta:    entry.Delta,
+		Issuance: entry.Issuance,
+		Burn:     entry.Burn,
+	}
+}
+
+// Copy returns a deep copy of the era total
+func (e *EraTotal) Copy() EraTotal {
+	return EraTotal{
+		Era:      e.Era,
+		Since:    e.Since,
+		Delta:    new(big.Int).Set(e.Delta),
+		Issuance: e.Issuance.Copy(),
+		Burn:     e.Burn.Copy(),
+	}
+}
+
+func (e EraTotal) MarshalJSON() ([]byte, error) {
+	type Alias EraTotal
+	enc := struct {
+		Alias
+		Delta *hexutil.Big `json:"delta"`
+	}{
+		Alias: (Alias)(e),
+		Delta: (*hexutil.Big)(e.Delta),
+	}
+
+	return json.Marshal(&enc)
+}
+
+func (e *EraTotal) UnmarshalJSON(input []byte) error {
+	type Alias EraTotal
+	dec := struct {
+		*Alias
+		Delta json.RawMessage `json:"delta"`
+	}{
+		Alias: (*Alias)(e),
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+
+	delta, err := supply.ParseSignedBig(dec.Delta)
+	if err != nil {
+		return fmt.Errorf("invalid delta of era %s: %v", e.Name, err)
+	}
+	e.Delta = delta
+
+	// set the missing components to zero
+	zero := newEraTotal(e.Era)
+	if e.Issuance == nil {
+		e.Issuance = zero.Issuance
+	}
+	if e.Burn == nil {
+		e.Burn = zero.Burn
+	}
+
+	return nil
+}
+
+// SetEras partitions the component totals of the state by the eras, sorted by their first block.
+// The era totals loaded from the state file are kept when their eras are unchanged,
+// otherwise the breakdown starts over after the current head, and the blocks up to it
+// are no longer accounted, also when they are reorged.
+// Blocks before the first era, and the totals of a seed, are not accounted in any era.
+func (s *State) SetEras(eras []Era) {
+	s.Lock()
+	defer s.Unlock()
+
+	if sameEras(s.eraTotals, eras) {
+		return
+	}
+	if len(s.eraTotals) > 0 {
+		log.Printf("The eras changed, the per era totals start over after block %d", s.BlockNumber)
+	}
+
+	since := uint64(0)
+	if s.Hash != (common.Hash{}) {
+		since = s.BlockNumber + 1
+	}
+
+	s.eraTotals = make([]*EraTotal, 0, len(eras))
+	for _, era := range eras {
+		total := newEraTotal(era)
+		if since > era.From {
+			total.Since = since
+		}
+		s.eraTotals = append(s.eraTotals, total)
+	}
+}
+
+func sameEras(totals []*EraTotal, eras []Era) bool {
+	if len(totals) != len(eras) {
+		return false
+	}
+	for i, total := range totals {
+		if total.Era != eras[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// EraTotals returns a copy of the per era totals
+func (s *State) EraTotals() []EraTotal {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.copyEraTotals()
+}
+
+// copyEraTotals returns a copy of the per era totals.
+// It has to be called while holding the lock.
+func (s *State) copyEraTotals() []EraTotal {
+	totals := make([]EraTotal, 0, len(s.eraTotals))
+	for _, total := range s.eraTotals {
+		totals = append(totals, total.Copy())
+	}
+
+	return totals
+}
+
+// eraOf returns the total of the era of the block number, or nil if it is before the first era.
+// It has to be called while holding the lock.
+func (s *State) eraOf(number uint64) *EraTotal {
+	for i := len(s.eraTotals) - 1; i >= 0; i-- {
+		if s.eraTotals[i].From <= number {
+			return s.eraTotals[i]
+		}
+	}
+
+	return nil
+}
+
+// addToEra adds the supply data to the total of its era, or subtracts it when sub is set.
+// Blocks before the era total started are skipped, so that their reorgs do not subtract them.
+// It has to be called while holding the lock.
+func (s *State) addToEra(entry *supply.Info, sub bool) {
+	era := s.eraOf(entry.Number)
+	if era == nil || entry.Number < era.Since {
+		return
+	}
+
+	op := (*big.Int).Add
+	if sub {
+		op = (*big.Int).Sub
+	}
+	op(era.Issuance.GenesisAlloc, era.Issuance.GenesisAlloc, entry.Issuance.GenesisAlloc)
+	op(era.Issuance.Reward, era.Issuance.Reward, entry.Issuance.Reward)
+	op(era.Issuance.Withdrawals, era.Issuance.Withdrawals, entry.Issuance.Withdrawals)
+	op(era.Burn.EIP1559, era.Burn.EIP1559, entry.Burn.EIP1559)
+	op(era.Burn.Blob, era.Burn.Blob, entry.Burn.Blob)
+	op(era.Burn.Misc, era.Burn.Misc, entry.Burn.Misc)
+	era.Issuance.Other = supply.AddOther(era.Issuance.Other, entry.Issuance.Other, sub)
+	era.Burn.Other = supply.AddOther(era.Burn.Other, entry.Burn.Other, sub)
+
+	entry.AddDeltaTo(era.Delta, sub)
+}
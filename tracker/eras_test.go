@@ -0,0 +1,124 @@
+package tracker
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParseEras(t *testing.T) {
+	eras, err := ParseEras("shanghai=17034870, paris=15537394")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eras) != 2 || eras[0] != (Era{"paris", 15537394}) || eras[1] != (Era{"shanghai", 17034870}) {
+		t.Errorf("unexpected eras: %v", eras)
+	}
+
+	for _, spec := range []string{"", "paris", "paris=x", "a=1,b=1"} {
+		if _, err := ParseEras(spec); err == nil {
+			t.Errorf("expected error for eras %q", spec)
+		}
+	}
+}
+
+func TestEraTotals(t *testing.T) {
+	s := NewState()
+	s.SetEras([]Era{{"pow", 1}, {"pos", 3}})
+
+	// Block 0 is before the first era
+	for n := uint64(0); n < 5; n++ {
+		entry := newSupplyInfo()
+		entry.Number = n
+		entry.Hash = common.Hash{byte(n)}
+		entry.ParentHash = common.Hash{byte(n - 1)}
+		entry.Issuance.Reward = big.NewInt(2)
+		entry.Burn.EIP1559 = big1
+		s.add(&entry)
+	}
+
+	totals := s.EraTotals()
+	if len(totals) != 2 {
+		t.Fatalf("want 2 eras, have %d", len(totals))
+	}
+	if totals[0].Issuance.Reward.Int64() != 4 || totals[0].Delta.Int64() != 2 {
+		t.Errorf("unexpected pow era total: %+v", totals[0])
+	}
+	if totals[1].Issuance.Reward.Int64() != 4 || totals[1].Burn.EIP1559.Int64() != 2 {
+		t.Errorf("unexpected pos era total: %+v", totals[1])
+	}
+
+	// Reverted blocks are subtracted from their era
+	entry := newSupplyInfo()
+	entry.Number = 4
+	entry.Issuance.Reward = big.NewInt(2)
+	entry.Burn.EIP1559 = big1
+	s.sub(&entry)
+	if total := s.EraTotals()[1]; total.Issuance.Reward.Int64() != 2 || total.Delta.Int64() != 1 {
+		t.Errorf("unexpected pos era total after sub: %+v", total)
+	}
+
+	// The era totals are persisted
+	path := filepath.Join(t.TempDir(), "state.json")
+	s.SaveState(path, "supply.jsonl")
+	loaded := NewState()
+	if _, err := loaded.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded.SetEras([]Era{{"pow", 1}, {"pos", 3}})
+	if total := loaded.EraTotals()[1]; total.Issuance.Reward.Int64() != 2 || total.Delta.Int64() != 1 {
+		t.Errorf("unexpected loaded pos era total: %+v", total)
+	}
+
+	// Changed eras start over
+	loaded.SetEras([]Era{{"all", 0}})
+	if totals := loaded.EraTotals(); len(totals) != 1 || totals[0].Delta.Sign() != 0 {
+		t.Errorf("unexpected totals of changed eras: %+v", totals)
+	}
+}
+
+func TestEraTotalsReorgBeforeStart(t *testing.T) {
+	s := NewState()
+	errCh := make(chan error, 16)
+	handle := func(number uint64, hash, parent common.Hash, reward, burn int64) {
+		entry := newSupplyInfo()
+		entry.Number = number
+		entry.Hash = hash
+		entry.ParentHash = parent
+		entry.Issuance.Reward = big.NewInt(reward)
+		entry.Burn.Misc = big.NewInt(burn)
+		entry.Delta = entry.CalculatedDelta()
+		s.HandleEntry(entry, errCh)
+	}
+	for n := uint64(0); n < 4; n++ {
+		handle(n, common.Hash{byte(n)}, common.Hash{byte(n - 1)}, 2, 0)
+	}
+
+	// The eras are set after block 3, which is then reorged
+	s.SetEras([]Era{{"all", 0}})
+	handle(4, common.Hash{4}, common.Hash{3}, 2, 0)
+	handle(3, common.Hash{3, 1}, common.Hash{2}, 1, 5)
+	if len(errCh) != 0 {
+		t.Fatalf("unexpected error: %v", <-errCh)
+	}
+
+	total := s.EraTotals()[0]
+	if total.Since != 4 || total.Issuance.Reward.Sign() != 0 || total.Burn.Misc.Sign() != 0 || total.Delta.Sign() != 0 {
+		t.Errorf("want an empty era total since block 4, have %+v", total)
+	}
+
+	// Negative era totals are persisted
+	handle(4, common.Hash{4, 1}, common.Hash{3, 1}, 1, 5)
+	path := filepath.Join(t.TempDir(), "state.json")
+	s.SaveState(path, "supply.jsonl")
+	loaded := NewState()
+	if _, err := loaded.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	total = loaded.EraTotals()[0]
+	if total.Since != 4 || total.Delta.Int64() != -4 || total.Burn.Misc.Int64() != 5 || total.Issuance.Reward.Int64() != 1 {
+		t.Errorf("unexpected loaded era total: %+v", total)
+	}
+}
@@ -0,0 +1,151 @@
+package tracker
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+// BlockRef identifies a block
+type BlockRef struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+// Reorg describes a chain reorganisation, where the head of the state
+// has been replaced by a block that does not extend it
+type Reorg struct {
+	OldHead BlockRef `json:"oldHead"`
+	NewHead BlockRef `json:"newHead"`
+	Depth   int      `json:"depth"` // Number of blocks of the old chain that were reverted
+}
+
+// Hooks are callbacks invoked on state transitions. Any of them can be nil.
+//
+// Callbacks are invoked synchronously from the goroutine updating the state,
+// so they should hand off any long running work. They are invoked without holding
+// the state lock and receive a copy of the total supply at the time of the transition,
+// so they can safely read the state or (un)subscribe.
+type Hooks struct {
+	// OnHead is invoked when an entry has been applied as the new head
+	OnHead func(entry supply.Info, total TotalSupply)
+	// OnReorg is invoked after OnHead, when the new head replaced blocks of the canonical chain
+	OnReorg func(reorg Reorg, total TotalSupply)
+	// OnRewind is invoked when the state has been rewound to an older block
+	OnRewind func(from, to BlockRef, depth int, total TotalSupply)
+	// OnForward is invoked when the state has been forwarded to a newer block from history
+	OnForward func(from, to BlockRef, depth int, total TotalSupply)
+	// OnInvalidEntry is invoked when an entry is skipped, because it does not link to the chain of the state
+	OnInvalidEntry func(entry supply.Info, err error)
+	// OnCheckpoint is invoked when the state has been saved to a file
+	OnCheckpoint func(path, lastParsedFilename string, total TotalSupply)
+}
+
+// Subscribe registers hooks to be invoked on state transitions.
+// It returns a function that unregisters them.
+func (s *State) Subscribe(hooks *Hooks) (unsubscribe func()) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	s.hooks = append(s.hooks, hooks)
+
+	return func() {
+		s.hooksMu.Lock()
+		defer s.hooksMu.Unlock()
+
+		for i, h := range s.hooks {
+			if h == hooks {
+				s.hooks = append(s.hooks[:i:i], s.hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// subscribers returns the registered hooks.
+// The returned slice is never modified, so it can be iterated without holding the lock.
+func (s *State) subscribers() []*Hooks {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	return s.hooks
+}
+
+// head returns the current head of the state
+func (s *State) head() BlockRef {
+	s.RLock()
+	defer s.RUnlock()
+
+	return BlockRef{Number: s.BlockNumber, Hash: s.Hash}
+}
+
+func (s *State) notifyHead(entry supply.Info) {
+	var total *TotalSupply
+	for _, h := range s.subscribers() {
+		if h.OnHead == nil {
+			continue
+		}
+		if total == nil {
+			snapshot := s.Snapshot()
+			total = &snapshot
+		}
+		h.OnHead(entry, total.Copy())
+	}
+}
+
+func (s *State) notifyReorg(reorg Reorg) {
+	var total *TotalSupply
+	for _, h := range s.subscribers() {
+		if h.OnReorg == nil {
+			continue
+		}
+		if total == nil {
+			snapshot := s.Snapshot()
+			total = &snapshot
+		}
+		h.OnReorg(reorg, total.Copy())
+	}
+}
+
+func (s *State) notifyRewind(from, to BlockRef, depth int) {
+	var total *TotalSupply
+	for _, h := range s.subscribers() {
+		if h.OnRewind == nil {
+			continue
+		}
+		if total == nil {
+			snapshot := s.Snapshot()
+			total = &snapshot
+		}
+		h.OnRewind(from, to, depth, total.Copy())
+	}
+}
+
+func (s *State) notifyForward(from, to BlockRef, depth int) {
+	var total *TotalSupply
+	for _, h := range s.subscribers() {
+		if h.OnForward == nil {
+			continue
+		}
+		if total == nil {
+			snapshot := s.Snapshot()
+			total = &snapshot
+		}
+		h.OnForward(from, to, depth, total.Copy())
+	}
+}
+
+func (s *State) notifyInvalidEntry(entry supply.Info, err error) {
+	for _, h := range s.subscribers() {
+		if h.OnInvalidEntry != nil {
+			h.OnInvalidEntry(entry, err)
+		}
+	}
+}
+
+func (s *State) notifyCheckpoint(path, lastParsedFilename string, total TotalSupply) {
+	for _, h := range s.subscribers() {
+		if h.OnCheckpoint != nil {
+			h.OnCheckpoint(path, lastParsedFilename, total.Copy())
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package tracker
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+func TestHooks(t *testing.T) {
+	s := NewState()
+
+	var (
+		heads       []uint64
+		reorgs      []Reorg
+		rewinds     int
+		checkpoints []string
+	)
+	unsubscribe := s.Subscribe(&Hooks{
+		OnHead: func(entry supply.Info, total TotalSupply) {
+			// Reading the state from a callback must not deadlock
+			s.RLock()
+			number := s.BlockNumber
+			s.RUnlock()
+
+			if number != entry.Number || total.BlockNumber != entry.Number {
+				t.Errorf("OnHead got inconsistent snapshot: entry %d, state %d, snapshot %d", entry.Number, number, total.BlockNumber)
+			}
+			heads = append(heads, entry.Number)
+		},
+		OnReorg: func(reorg Reorg, total TotalSupply) {
+			reorgs = append(reorgs, reorg)
+		},
+		OnRewind: func(from, to BlockRef, depth int, total TotalSupply) {
+			rewinds++
+		},
+		OnCheckpoint: func(path, lastParsedFilename string, total TotalSupply) {
+			checkpoints = append(checkpoints, lastParsedFilename)
+		},
+	})
+
+	errCh := make(chan error, 16)
+	for i := uint64(0); i < 4; i++ {
+		block := newSupplyInfo()
+		block.Number = i
+		block.Issuance.Reward = big1
+		block.Hash = common.Hash{byte(i)}
+		block.ParentHash = common.Hash{byte(i - 1)}
+
+		s.HandleEntry(block, errCh)
+	}
+
+	// Replace block 3
+	block := newSupplyInfo()
+	block.Number = 3
+	block.Issuance.Reward = big.NewInt(2)
+	block.Hash = common.Hash{3, 1}
+	block.ParentHash = common.Hash{2}
+	s.HandleEntry(block, errCh)
+
+	if len(errCh) != 0 {
+		t.Fatalf("unexpected error: %v", <-errCh)
+	}
+
+	if len(heads) != 5 || heads[4] != 3 {
+		t.Errorf("OnHead invoked for %v", heads)
+	}
+	if rewinds != 1 {
+		t.Errorf("OnRewind invoked %d times, want 1", rewinds)
+	}
+	if len(reorgs) != 1 {
+		t.Fatalf("OnReorg invoked %d times, want 1", len(reorgs))
+	}
+	want := Reorg{
+		OldHead: BlockRef{Number: 3, Hash: common.Hash{3}},
+		NewHead: BlockRef{Number: 3, Hash: common.Hash{3, 1}},
+		Depth:   1,
+	}
+	if reorgs[0] != want {
+		t.Errorf("OnReorg want %+v, have %+v", want, reorgs[0])
+	}
+
+	s.SaveState(filepath.Join(t.TempDir(), "state.json"), "supply-1.jsonl")
+	if len(checkpoints) != 1 || checkpoints[0] != "supply-1.jsonl" {
+		t.Errorf("OnCheckpoint invoked for %v", checkpoints)
+	}
+
+	// No more callbacks after unsubscribing
+	unsubscribe()
+
+	block = newSupplyInfo()
+	block.Number = 4
+	block.Hash = common.Hash{4}
+	block.ParentHash = common.Hash{3, 1}
+	s.HandleEntry(block, errCh)
+
+	if len(heads) != 5 {
+		t.Errorf("OnHead invoked after unsubscribing")
+	}
+}
+
+func TestHooksSnapshotIsolation(t *testing.T) {
+	s := NewState()
+
+	s.Subscribe(&Hooks{
+		OnHead: func(entry supply.Info, total TotalSupply) {
+			// Modifying the snapshot must not affect the state
+			total.Delta.SetInt64(100)
+			total.Issuance.Reward.SetInt64(100)
+		},
+	})
+
+	block := newSupplyInfo()
+	block.Issuance.Reward = big1
+	s.HandleEntry(block, make(chan error, 1))
+
+	if s.Delta.Cmp(big1) != 0 || s.Issuance.Reward.Cmp(big1) != 0 {
+		t.Errorf("hook modified the state: delta %s, reward %s", s.Delta, s.Issuance.Reward)
+	}
+}
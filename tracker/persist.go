@@ -0,0 +1,169 @@
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+// Schema versions of the total supply in the state file and the API
+const (
+	SchemaV1 = 1 // Absolute hex delta, with its sign in `deltaSign`
+	SchemaV2 = 2 // Signed hex delta
+	SchemaV3 = 3 // Per era totals
+	SchemaV4 = 4 // Time series
+	SchemaV5 = 5 // Seed block
+
+	// LatestSchema is the schema version of the state file
+	LatestSchema = SchemaV5
+)
+
+// ErrNewerSchema is returned when loading a state file of an unknown newer schema version
+var ErrNewerSchema = errors.New("state file schema version is newer than the supported one")
+
+// migrations upgrade the raw fields of a state file from the schema version
+// of their index to the next one. The version is bumped after each of them, and the
+// schema versions that only added optional fields (per era totals, time series, seed block) need none.
+var migrations = map[int]func(fields map[string]json.RawMessage) error{
+	SchemaV1: migrateV1,
+}
+
+// PersistedState is the state stored in the state file.
+// State files without a version are of schema version 1.
+type PersistedState struct {
+	TotalSupply
+	File    string                `json:"file"`
+	Eras    []EraTotal            `json:"eras"`
+	Series  map[Interval][]Bucket `json:"series"`
+	Seed    *BlockRef             `json:"seed"`    // Starting block of a seeded state, nil otherwise
+	Version int                   `json:"version"` // Schema version the state was read from
+}
+
+// stateFile is the layout of the state file in the latest schema version
+type stateFile struct {
+	Version     int                   `json:"version"`
+	File        string                `json:"file"`
+	BlockNumber uint64                `json:"blockNumber"`
+	Hash        common.Hash           `json:"hash"`
+	ParentHash  common.Hash           `json:"parentHash"`
+	Delta       *hexutil.Big          `json:"delta"`
+	Issuance    *supply.Issuance      `json:"issuance,omitempty"`
+	Burn        *supply.Burn          `json:"burn,omitempty"`
+	Eras        []EraTotal            `json:"eras,omitempty"`
+	Series      map[Interval][]Bucket `json:"series,omitempty"`
+	Seed        *BlockRef             `json:"seed,omitempty"`
+}
+
+// MarshalJSON marshals the state in the latest schema version
+func (ps PersistedState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(stateFile{
+		Version:     LatestSchema,
+		File:        ps.File,
+		BlockNumber: ps.BlockNumber,
+		Hash:        ps.Hash,
+		ParentHash:  ps.ParentHash,
+		Delta:       (*hexutil.Big)(ps.Delta),
+		Issuance:    ps.Issuance,
+		Burn:        ps.Burn,
+		Eras:        ps.Eras,
+		Series:      ps.Series,
+		Seed:        ps.Seed,
+	})
+}
+
+// UnmarshalJSON migrates the state to the latest schema version and unmarshals it.
+// It refuses schema versions newer than the latest known one.
+func (ps *PersistedState) UnmarshalJSON(input []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(input, &fields); err != nil {
+		return err
+	}
+
+	version := SchemaV1
+	if raw, ok := fields["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return fmt.Errorf("invalid schema version: %v", err)
+		}
+	}
+	if version < SchemaV1 {
+		return fmt.Errorf("invalid schema version %d", version)
+	}
+	if version > LatestSchema {
+		return fmt.Errorf("%w: have version %d, support up to %d, upgrade to read this state file", ErrNewerSchema, version, LatestSchema)
+	}
+
+	for v := version; v < LatestSchema; v++ {
+		if migrate, ok := migrations[v]; ok {
+			if err := migrate(fields); err != nil {
+				return fmt.Errorf("failed to migrate from schema version %d to %d: %v", v, v+1, err)
+			}
+		}
+		fields["version"] = json.RawMessage(fmt.Sprint(v + 1))
+	}
+
+	migrated, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	// hexutil.Big does not unmarshal negative values, so the signed delta is parsed separately
+	var dec struct {
+		stateFile
+		Delta json.RawMessage `json:"delta"`
+	}
+	if err := json.Unmarshal(migrated, &dec); err != nil {
+		return err
+	}
+	delta := new(big.Int)
+	if len(dec.Delta) > 0 && string(dec.Delta) != "null" {
+		if delta, err = supply.ParseSignedBig(dec.Delta); err != nil {
+			return fmt.Errorf("invalid delta: %v", err)
+		}
+	}
+
+	ps.Version = version
+	ps.File = dec.File
+	ps.BlockNumber = dec.BlockNumber
+	ps.Hash = dec.Hash
+	ps.ParentHash = dec.ParentHash
+	ps.Delta = delta
+	ps.Issuance = dec.Issuance
+	ps.Burn = dec.Burn
+	ps.Eras = dec.Eras
+	ps.Series = dec.Series
+	ps.Seed = dec.Seed
+
+	return nil
+}
+
+// migrateV1 replaces the absolute delta and its `deltaSign` with a signed delta
+func migrateV1(fields map[string]json.RawMessage) error {
+	if raw, ok := fields["delta"]; ok && string(raw) != "null" {
+		delta, err := supply.ParseSignedBig(raw)
+		if err != nil {
+			return fmt.Errorf("invalid delta: %v", err)
+		}
+
+		var sign string
+		if rawSign, ok := fields["deltaSign"]; ok {
+			if err := json.Unmarshal(rawSign, &sign); err != nil {
+				return fmt.Errorf("invalid deltaSign: %v", err)
+			}
+		}
+		if sign == "-" {
+			delta.Neg(delta)
+		}
+
+		fields["delta"], err = json.Marshal((*hexutil.Big)(delta))
+		if err != nil {
+			return err
+		}
+	}
+	delete(fields, "deltaSign")
+
+	return nil
+}
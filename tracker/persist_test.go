@@ -0,0 +1,70 @@
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestStateFileSchemas(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name  string
+		state string
+		delta *big.Int
+	}{
+		{"v1 negative", `{"blockNumber":2,"delta":"0x5","deltaSign":"-","file":"supply-1.jsonl"}`, big.NewInt(-5)},
+		{"v1 positive", `{"blockNumber":2,"delta":"0x5","deltaSign":"+","file":"supply-1.jsonl"}`, big.NewInt(5)},
+		{"v2 negative", `{"blockNumber":2,"delta":"-0x5","file":"supply-1.jsonl","version":2}`, big.NewInt(-5)},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "state.json")
+		if err := os.WriteFile(path, []byte(tt.state), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		s := NewState()
+		file, err := s.LoadState(path)
+		if err != nil {
+			t.Fatalf("%s: failed to load state: %v", tt.name, err)
+		}
+		if file != "supply-1.jsonl" || s.BlockNumber != 2 || s.Delta.Cmp(tt.delta) != 0 {
+			t.Errorf("%s: loaded file %s, block %d, delta %s", tt.name, file, s.BlockNumber, s.Delta)
+		}
+
+		// Saving migrates to the latest schema
+		s.SaveState(path, file)
+		saved, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(saved, &data); err != nil {
+			t.Fatal(err)
+		}
+		if data["delta"] != hexutil.EncodeBig(tt.delta) || data["version"] != float64(LatestSchema) {
+			t.Errorf("%s: unexpected saved state %s", tt.name, saved)
+		}
+		if _, ok := data["deltaSign"]; ok {
+			t.Errorf("%s: saved state has a deltaSign", tt.name)
+		}
+	}
+}
+
+func TestStateFileNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"blockNumber":2,"delta":"0x5","file":"supply-1.jsonl","version":99}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewState().LoadState(path)
+	if !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("want an error for a newer schema version, have %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package tracker
+
+import (
+	"encoding/json"
+	"expvar"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// RateWindow is a sliding window of the supply rates, ending at the head
+type RateWindow struct {
+	Name     string
+	Duration time.Duration
+}
+
+// RateWindows are the windows of the supply rates
+var RateWindows = []RateWindow{
+	{"1h", time.Hour},
+	{"1d", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+// year is the duration the rates are annualised to
+const year = 365.25 * 24 * 60 * 60
+
+// Rate is the issuance, burn and net delta of the blocks within a window
+type Rate struct {
+	Window string `json:"window"`
+	From   uint64 `json:"from"` // Unix time of the start of the covered blocks, aligned to the hour
+	To     uint64 `json:"to"`   // Unix time of the head
+	Blocks int    `json:"blocks"`
+
+	Issuance *big.Int `json:"issuance"`
+	Burn     *big.Int `json:"burn"`
+	Delta    *big.Int `json:"delta"`
+
+	IssuanceRate *big.Int `json:"issuanceRate"` // Annualised issuance
+	BurnRate     *big.Int `json:"burnRate"`     // Annualised burn
+	DeltaRate    *big.Int `json:"deltaRate"`    // Annualised net delta
+
+	Inflation  *float64 `json:"inflation,omitempty"` // Annualised net delta as a percentage of the absolute total supply, when known
+	Ultrasound bool     `json:"ultrasound"`          // Whether the supply shrank within the window
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	type Alias Rate
+	enc := struct {
+		Alias
+		Issuance     *hexutil.Big `json:"issuance"`
+		Burn         *hexutil.Big `json:"burn"`
+		Delta        *hexutil.Big `json:"delta"`
+		IssuanceRate *hexutil.Big `json:"issuanceRate"`
+		BurnRate     *hexutil.Big `json:"burnRate"`
+		DeltaRate    *hexutil.Big `json:"deltaRate"`
+	}{
+		Alias:        (Alias)(r),
+		Issuance:     (*hexutil.Big)(r.Issuance),
+		Burn:         (*hexutil.Big)(r.Burn),
+		Delta:        (*hexutil.Big)(r.Delta),
+		IssuanceRate: (*hexutil.Big)(r.IssuanceRate),
+		BurnRate:     (*hexutil.Big)(r.BurnRate),
+		DeltaRate:    (*hexutil.Big)(r.DeltaRate),
+	}
+
+	return json.Marshal(&enc)
+}
+
+// Rates returns the supply rates of the windows, from the hourly buckets of the block timestamps.
+// The windows are aligned to whole hours, and the rates are annualised by the covered time.
+// It returns no rates when no block with a timestamp has been handled.
+func (s *State) Rates() []Rate {
+	s.RLock()
+	defer s.RUnlock()
+
+	buckets := s.series[Hour]
+	if len(buckets) == 0 {
+		return []Rate{}
+	}
+
+	// The windows end at the head, or at the end of the newest bucket when its time is unknown
+	end := s.headTimestamp
+	if end == 0 {
+		for start := range buckets {
+			if start+3600 > end {
+				end = start + 3600
+			}
+		}
+	}
+
+	rates := make([]Rate, 0, len(RateWindows))
+	for _, window := range RateWindows {
+		rate := Rate{
+			Window:   window.Name,
+			From:     end,
+			To:       end,
+			Issuance: new(big.Int),
+			Burn:     new(big.Int),
+			Delta:    new(big.Int),
+		}
+
+		from := uint64(0)
+		if seconds := uint64(window.Duration.Seconds()); end > seconds {
+			from = end - seconds
+		}
+		for start, bucket := range buckets {
+			if start+3600 <= from || start > end {
+				continue
+			}
+			if start < rate.From {
+				rate.From = start
+			}
+			rate.Blocks += bucket.Blocks
+			rate.Issuance.Add(rate.Issuance, bucket.Issuance)
+			rate.Burn.Add(rate.Burn, bucket.Burn)
+			rate.Delta.Add(rate.Delta, bucket.Delta)
+		}
+
+		rate.IssuanceRate = annualise(rate.Issuance, rate.To-rate.From)
+		rate.BurnRate = annualise(rate.Burn, rate.To-rate.From)
+		rate.DeltaRate = annualise(rate.Delta, rate.To-rate.From)
+		rate.Ultrasound = rate.Delta.Sign() < 0
+
+		if s.absoluteSupply() && s.Delta.Sign() > 0 {
+			inflation, _ := new(big.Float).Quo(new(big.Float).SetInt(rate.DeltaRate), new(big.Float).SetInt(s.Delta)).Float64()
+			inflation *= 100
+			rate.Inflation = &inflation
+		}
+
+		rates = append(rates, rate)
+	}
+
+	return rates
+}
+
+// absoluteSupply returns whether the total supply of the state is absolute, rather than
+// relative to the first traced block: when it was seeded, anchored to a known total supply,
+// or traced from the genesis allocation. It has to be called while holding the lock.
+func (s *State) absoluteSupply() bool {
+	if s.seed != nil || s.Issuance.GenesisAlloc.Sign() > 0 {
+		return true
+	}
+	_, issued := s.Issuance.Other[AnchorCategory]
+	_, burnt := s.Burn.Other[AnchorCategory]
+
+	return issued || burnt
+}
+
+var (
+	publishRates sync.Once
+	ratesState   atomic.Pointer[State]
+)
+
+// PublishRates publishes the rates of the state in the `supply_rates` metric,
+// by window, with the annualised amounts in wei. Later calls publish the rates of their state instead.
+func PublishRates(s *State) {
+	ratesState.Store(s)
+	publishRates.Do(func() {
+		expvar.Publish("supply_rates", expvar.Func(rateMetrics))
+	})
+}
+
+func rateMetrics() interface{} {
+	metrics := make(map[string]map[string]interface{})
+	for _, rate := range ratesState.Load().Rates() {
+		metric := map[string]interface{}{
+			"blocks":       rate.Blocks,
+			"issuanceRate": toFloat(rate.IssuanceRate),
+			"burnRate":     toFloat(rate.BurnRate),
+			"deltaRate":    toFloat(rate.DeltaRate),
+			"ultrasound":   rate.Ultrasound,
+		}
+		if rate.Inflation != nil {
+			metric["inflation"] = *rate.Inflation
+		}
+		metrics[rate.Window] = metric
+	}
+
+	return metrics
+}
+
+func toFloat(n *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(n).Float64()
+	return f
+}
+
+// annualise scales the amount of the duration in seconds to a year
+func annualise(amount *big.Int, seconds uint64) *big.Int {
+	if seconds == 0 {
+		return new(big.Int)
+	}
+	scaled := new(big.Int).Mul(amount, big.NewInt(int64(year)))
+
+	return scaled.Quo(scaled, new(big.Int).SetUint64(seconds))
+}
@@ -0,0 +1,85 @@
+package tracker
+
+import (
+	"expvar"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+func TestRates(t *testing.T) {
+	s := NewState()
+	if rates := s.Rates(); len(rates) != 0 {
+		t.Errorf("want no rates without timestamps, have %+v", rates)
+	}
+
+	// A block every 12 seconds for two hours, starting at an hour
+	start := uint64(1681336800)
+	var last supply.Info
+	for i := uint64(0); i < 600; i++ {
+		entry := newSupplyInfo()
+		entry.Number = i
+		entry.Hash = common.BigToHash(new(big.Int).SetUint64(i + 1))
+		entry.Timestamp = start + i*12
+		entry.Issuance.Reward = big.NewInt(2)
+		entry.Burn.EIP1559 = big.NewInt(3)
+		s.setHead(&entry)
+		s.add(&entry)
+		last = entry
+	}
+
+	rates := s.Rates()
+	if len(rates) != len(RateWindows) {
+		t.Fatalf("want %d rates, have %d", len(RateWindows), len(rates))
+	}
+
+	// The 1h window covers both hourly buckets, as the head is not at an hour
+	hour := rates[0]
+	if hour.Window != "1h" || hour.From != start || hour.To != last.Timestamp || hour.Blocks != 600 {
+		t.Errorf("unexpected 1h window: %+v", hour)
+	}
+	if hour.Delta.Int64() != -600 || !hour.Ultrasound {
+		t.Errorf("unexpected 1h delta %s, ultrasound %v", hour.Delta, hour.Ultrasound)
+	}
+	wantRate := big.NewInt(-600 * int64(year) / int64(last.Timestamp-start))
+	if hour.DeltaRate.Cmp(wantRate) != 0 {
+		t.Errorf("want 1h delta rate %s, have %s", wantRate, hour.DeltaRate)
+	}
+
+	// Inflation is relative to the absolute total supply
+	if hour.Inflation != nil {
+		t.Errorf("want no inflation with a negative total supply, have %v", *hour.Inflation)
+	}
+	s.Delta.SetInt64(1e18)
+	if rates := s.Rates(); rates[0].Inflation != nil {
+		t.Errorf("want no inflation with a total supply relative to the first block, have %v", *rates[0].Inflation)
+	}
+	s.seed = &BlockRef{Number: 0}
+	if rates := s.Rates(); rates[0].Inflation == nil || *rates[0].Inflation >= 0 {
+		t.Errorf("want negative inflation, have %v", rates[0].Inflation)
+	}
+
+	// Reverted blocks are subtracted
+	s.sub(&last)
+	if rates := s.Rates(); rates[3].Blocks != 599 || rates[3].Delta.Int64() != -599 {
+		t.Errorf("unexpected 30d window after sub: %+v", rates[3])
+	}
+}
+
+func TestPublishRatesTwice(t *testing.T) {
+	PublishRates(NewState())
+
+	s := NewState()
+	entry := newSupplyInfo()
+	entry.Timestamp = 1681336800
+	entry.Issuance.Reward = big.NewInt(2)
+	s.add(&entry)
+	PublishRates(s)
+
+	metrics, ok := expvar.Get("supply_rates").(expvar.Func).Value().(map[string]map[string]interface{})
+	if !ok || metrics["1h"]["blocks"] != 1 {
+		t.Errorf("want the rates of the last published state, have %v", metrics)
+	}
+}
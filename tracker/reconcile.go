@@ -0,0 +1,240 @@
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+// KnownSupply is an externally known total supply at a block,
+// e.g. from a state-scan snapshot
+type KnownSupply struct {
+	BlockNumber uint64      `json:"blockNumber"`
+	Hash        common.Hash `json:"hash"` // Optional, checked against the canonical chain when set
+	TotalSupply *big.Int    `json:"totalSupply"`
+}
+
+func (k *KnownSupply) UnmarshalJSON(input []byte) error {
+	type Alias KnownSupply
+	dec := struct {
+		*Alias
+		TotalSupply json.RawMessage `json:"totalSupply"`
+	}{
+		Alias: (*Alias)(k),
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+
+	if len(dec.TotalSupply) == 0 || string(dec.TotalSupply) == "null" {
+		return errors.New("missing totalSupply")
+	}
+	total, err := supply.ParseSignedBig(dec.TotalSupply)
+	if err != nil {
+		return fmt.Errorf("invalid totalSupply: %v", err)
+	}
+	k.TotalSupply = total
+
+	return nil
+}
+
+// LoadKnownSupply reads a known total supply from a JSON file
+func LoadKnownSupply(path string) (KnownSupply, error) {
+	var known KnownSupply
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return known, fmt.Errorf("failed to read known supply file: %v", err)
+	}
+	if err := json.Unmarshal(bytes, &known); err != nil {
+		return known, fmt.Errorf("failed to unmarshal known supply file: %v", err)
+	}
+
+	return known, nil
+}
+
+// Reconciliation is the result of comparing the tracked total supply with a known one
+type Reconciliation struct {
+	BlockNumber uint64       `json:"blockNumber"`
+	Hash        common.Hash  `json:"hash"`
+	Known       *hexutil.Big `json:"known"`
+	Tracked     *hexutil.Big `json:"tracked"`    // Tracked total supply at the block, before anchoring
+	Difference  *hexutil.Big `json:"difference"` // Known minus tracked, can be negative
+	Anchored    bool         `json:"anchored"`   // Whether the tracked total has been adjusted by the difference
+}
+
+// TotalAt returns the tracked total supply delta and the canonical hash of the block number.
+// The block has to be the head of the state, or within its history.
+func (s *State) TotalAt(number uint64) (*big.Int, common.Hash, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.totalAt(number)
+}
+
+func (s *State) totalAt(number uint64) (*big.Int, common.Hash, error) {
+	if number > s.BlockNumber {
+		return nil, common.Hash{}, fmt.Errorf("block %d is ahead of the state head %d", number, s.BlockNumber)
+	}
+	if number == s.BlockNumber {
+		return new(big.Int).Set(s.Delta), s.Hash, nil
+	}
+
+	// Revert the deltas of the canonical blocks after the requested one
+	total := new(big.Int).Set(s.Delta)
+	for n := s.BlockNumber; n > number; n-- {
+		entry, found := s.canonicalEntry(n)
+		if !found {
+			return nil, common.Hash{}, fmt.Errorf("block %d is not in history", n)
+		}
+		entry.AddDeltaTo(total, true)
+	}
+
+	hash, found := s.canonicalChain[number]
+	if !found {
+		return nil, common.Hash{}, fmt.Errorf("block %d is not in history", number)
+	}
+
+	return total, hash, nil
+}
+
+// CanonicalEntry returns the supply data of the canonical block with the given number,
+// if it is still in history
+func (s *State) CanonicalEntry(number uint64) (supply.Info, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.canonicalEntry(number)
+}
+
+// CanonicalHash returns the hash of the canonical block with the given number,
+// up to the head of the state
+func (s *State) CanonicalHash(number uint64) (common.Hash, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if number > s.BlockNumber {
+		return common.Hash{}, false
+	}
+	hash, found := s.canonicalChain[number]
+
+	return hash, found
+}
+
+// FinalizedNumber returns the number of the oldest block in history.
+// The canonical blocks up to it can no longer be reverted, as older blocks are not in history.
+// It returns false when the history is empty.
+func (s *State) FinalizedNumber() (uint64, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	oldest := s.HashHistory.Oldest()
+	if oldest == nil {
+		return 0, false
+	}
+
+	return oldest.Key, true
+}
+
+// canonicalEntry returns the supply data of the canonical block with the given number.
+// It has to be called while holding the lock.
+func (s *State) canonicalEntry(number uint64) (supply.Info, bool) {
+	hash, found := s.canonicalChain[number]
+	if !found {
+		return supply.Info{}, false
+	}
+	hashes, found := s.HashHistory.Get(number)
+	if !found {
+		return supply.Info{}, false
+	}
+	entry, found := hashes[hash]
+
+	return entry, found
+}
+
+// AnchorCategory is the category of the adjustments of the total supply by Reconcile,
+// an issuance when the known total supply is higher, a burn when it is lower
+const AnchorCategory = "anchor"
+
+// Reconcile compares the tracked total supply with a known one.
+// When anchor is set, the tracked total is adjusted by the difference,
+// so that it reports the known total supply at the block.
+func (s *State) Reconcile(known KnownSupply, anchor bool) (Reconciliation, error) {
+	if known.TotalSupply == nil {
+		return Reconciliation{}, errors.New("missing known total supply")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	tracked, hash, err := s.totalAt(known.BlockNumber)
+	if err != nil {
+		return Reconciliation{}, fmt.Errorf("cannot reconcile: %v", err)
+	}
+	if known.Hash != (common.Hash{}) && known.Hash != hash {
+		return Reconciliation{}, fmt.Errorf("cannot reconcile: block %d hash %s is not canonical, have %s", known.BlockNumber, known.Hash, hash)
+	}
+
+	difference := new(big.Int).Sub(known.TotalSupply, tracked)
+
+	result := Reconciliation{
+		BlockNumber: known.BlockNumber,
+		Hash:        hash,
+		Known:       (*hexutil.Big)(new(big.Int).Set(known.TotalSupply)),
+		Tracked:     (*hexutil.Big)(tracked),
+		Difference:  (*hexutil.Big)(difference),
+	}
+
+	if anchor && difference.Sign() != 0 {
+		s.addAnchor(known.BlockNumber, difference)
+		result.Anchored = true
+
+		log.Printf("Anchored total supply to %s at block %d, adjusted by %s", known.TotalSupply, known.BlockNumber, difference)
+	}
+
+	return result, nil
+}
+
+// addAnchor records the adjustment of the total supply at the block as the AnchorCategory
+// component, in the totals, the era and the series of the block. It is also recorded on the
+// entry of the block in history, so that reorgs revert and apply it again with the block.
+// It has to be called while holding the lock.
+func (s *State) addAnchor(number uint64, difference *big.Int) {
+	adjustment := supply.New()
+	adjustment.Number = number
+	if difference.Sign() > 0 {
+		adjustment.Issuance.Other = map[string]*big.Int{AnchorCategory: new(big.Int).Set(difference)}
+	} else {
+		adjustment.Burn.Other = map[string]*big.Int{AnchorCategory: new(big.Int).Neg(difference)}
+	}
+
+	if hash, found := s.canonicalChain[number]; found {
+		if hashes, found := s.HashHistory.Get(number); found {
+			if entry, found := hashes[hash]; found {
+				// The components can be shared with the handlers of the entry
+				entry.Issuance, entry.Burn = entry.Issuance.Copy(), entry.Burn.Copy()
+				entry.Issuance.Other = supply.AddOther(entry.Issuance.Other, adjustment.Issuance.Other, false)
+				entry.Burn.Other = supply.AddOther(entry.Burn.Other, adjustment.Burn.Other, false)
+				entry.Delta = entry.CalculatedDelta()
+				hashes[hash] = entry
+
+				adjustment.Timestamp = entry.Timestamp
+			}
+		}
+	}
+	adjustment.AddDeltaTo(adjustment.Delta, false)
+
+	s.Issuance.Other = supply.AddOther(s.Issuance.Other, adjustment.Issuance.Other, false)
+	s.Burn.Other = supply.AddOther(s.Burn.Other, adjustment.Burn.Other, false)
+	s.addToEra(&adjustment, false)
+	s.addToBuckets(&adjustment, false, 0)
+
+	adjustment.AddDeltaTo(s.Delta, false)
+}
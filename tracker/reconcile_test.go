@@ -0,0 +1,170 @@
+package tracker
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func newReconcileState(t *testing.T) *State {
+	s := NewState()
+
+	errCh := make(chan error, 16)
+	for i := uint64(0); i < 4; i++ {
+		block := newSupplyInfo()
+		block.Number = i
+		block.Issuance.Reward = big1
+		block.Hash = common.Hash{byte(i)}
+		block.ParentHash = common.Hash{byte(i - 1)}
+
+		s.HandleEntry(block, errCh)
+	}
+	if len(errCh) != 0 {
+		t.Fatalf("unexpected error: %v", <-errCh)
+	}
+
+	return s
+}
+
+func TestTotalAt(t *testing.T) {
+	s := newReconcileState(t)
+
+	total, hash, err := s.TotalAt(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total.Cmp(big.NewInt(2)) != 0 || hash != (common.Hash{1}) {
+		t.Errorf("want total 2 at %s, have %s at %s", common.Hash{1}, total, hash)
+	}
+
+	if _, _, err := s.TotalAt(4); err == nil {
+		t.Errorf("expected error for a block ahead of the head")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	s := newReconcileState(t)
+
+	known := KnownSupply{BlockNumber: 1, Hash: common.Hash{1}, TotalSupply: big.NewInt(10)}
+
+	result, err := s.Reconcile(known, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Difference.ToInt().Cmp(big.NewInt(8)) != 0 || result.Tracked.ToInt().Cmp(big.NewInt(2)) != 0 || result.Anchored {
+		t.Errorf("unexpected reconciliation: %+v", result)
+	}
+	if s.Delta.Cmp(big.NewInt(4)) != 0 {
+		t.Errorf("reconciliation without anchoring changed the total to %s", s.Delta)
+	}
+
+	// Anchoring adjusts the running total
+	if result, err = s.Reconcile(known, true); err != nil || !result.Anchored {
+		t.Fatalf("failed to anchor: %v", err)
+	}
+	if s.Delta.Cmp(big.NewInt(12)) != 0 {
+		t.Errorf("want anchored total 12, have %s", s.Delta)
+	}
+	if total, _, _ := s.TotalAt(1); total.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("want anchored total 10 at block 1, have %s", total)
+	}
+
+	// Non canonical hash
+	known.Hash = common.Hash{1, 1}
+	if _, err := s.Reconcile(known, false); err == nil {
+		t.Errorf("expected error for a non canonical hash")
+	}
+}
+
+func TestReconcileAnchorComponents(t *testing.T) {
+	s := NewState()
+	s.SetEras(NetworkEras["mainnet"])
+
+	errCh := make(chan error, 16)
+	for i := uint64(0); i < 4; i++ {
+		block := newSupplyInfo()
+		block.Number = i
+		block.Issuance.Reward = big1
+		block.Hash = common.Hash{byte(i)}
+		block.ParentHash = common.Hash{byte(i - 1)}
+		block.Timestamp = 3600 + i
+		block.Delta = block.CalculatedDelta()
+
+		s.HandleEntry(block, errCh)
+	}
+	if len(errCh) != 0 {
+		t.Fatalf("unexpected error: %v", <-errCh)
+	}
+
+	// A higher known total supply is recorded as issuance
+	if _, err := s.Reconcile(KnownSupply{BlockNumber: 1, TotalSupply: big.NewInt(10)}, true); err != nil {
+		t.Fatal(err)
+	}
+	if anchor := s.Issuance.Other[AnchorCategory]; anchor == nil || anchor.Int64() != 8 {
+		t.Errorf("want anchor issuance 8, have %v", anchor)
+	}
+	era := s.EraTotals()[0]
+	if anchor := era.Issuance.Other[AnchorCategory]; anchor == nil || anchor.Int64() != 8 || era.Delta.Int64() != 12 {
+		t.Errorf("want era anchor issuance 8 and delta 12, have %v and %s", anchor, era.Delta)
+	}
+	buckets := s.Series(Hour, 0, 3600)
+	if len(buckets) != 1 || buckets[0].Blocks != 4 || buckets[0].Issuance.Int64() != 12 || buckets[0].Delta.Int64() != 12 {
+		t.Errorf("unexpected anchored buckets %+v", buckets)
+	}
+
+	// A lower one as burn
+	if _, err := s.Reconcile(KnownSupply{BlockNumber: 3, TotalSupply: big.NewInt(9)}, true); err != nil {
+		t.Fatal(err)
+	}
+	if anchor := s.Burn.Other[AnchorCategory]; anchor == nil || anchor.Int64() != 3 || s.Delta.Int64() != 9 {
+		t.Errorf("want anchor burn 3 and total 9, have %v and %s", anchor, s.Delta)
+	}
+	total := s.Issuance.AddTo(new(big.Int), false)
+	if s.Burn.AddTo(total, true); total.Cmp(s.Delta) != 0 {
+		t.Errorf("want the components to sum to the total %s, have %s", s.Delta, total)
+	}
+}
+
+func TestReconcileAnchorReorg(t *testing.T) {
+	s := newReconcileState(t)
+
+	// The anchor of block 2 is reverted with it
+	if _, err := s.Reconcile(KnownSupply{BlockNumber: 2, TotalSupply: big.NewInt(10)}, true); err != nil {
+		t.Fatal(err)
+	}
+	if s.Delta.Int64() != 11 {
+		t.Fatalf("want anchored total 11, have %s", s.Delta)
+	}
+
+	errCh := make(chan error, 16)
+	side := newSupplyInfo()
+	side.Number = 2
+	side.Issuance.Reward = big1
+	side.Hash = common.Hash{2, 1}
+	side.ParentHash = common.Hash{1}
+	s.HandleEntry(side, errCh)
+	if len(errCh) != 0 {
+		t.Fatalf("unexpected error: %v", <-errCh)
+	}
+	if s.Delta.Int64() != 3 || s.Issuance.Other[AnchorCategory].Sign() != 0 {
+		t.Errorf("want total 3 without the anchor after the reorg, have %s and anchor %v", s.Delta, s.Issuance.Other[AnchorCategory])
+	}
+
+	// And applied again when the block is canonical again
+	block := newSupplyInfo()
+	block.Number = 3
+	block.Issuance.Reward = big1
+	block.Hash = common.Hash{3}
+	block.ParentHash = common.Hash{2}
+	s.HandleEntry(block, errCh)
+	if len(errCh) != 0 {
+		t.Fatalf("unexpected error: %v", <-errCh)
+	}
+	if s.Delta.Int64() != 11 || s.Issuance.Other[AnchorCategory].Int64() != 7 {
+		t.Errorf("want anchored total 11 back, have %s and anchor %v", s.Delta, s.Issuance.Other[AnchorCategory])
+	}
+	if total, _, _ := s.TotalAt(2); total.Int64() != 10 {
+		t.Errorf("want the known total 10 at block 2, have %s", total)
+	}
+}
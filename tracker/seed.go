@@ -0,0 +1,120 @@
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+// Seed is a known starting point of the state, used when tracing starts mid-chain.
+// The total supply is the absolute supply at the block, while the component
+// totals are optional and default to zero.
+// The part of the total supply not covered by the components is accounted in SeedCategory.
+type Seed struct {
+	KnownSupply
+
+	Issuance *supply.Issuance `json:"issuance,omitempty"`
+	Burn     *supply.Burn     `json:"burn,omitempty"`
+}
+
+func (s *Seed) UnmarshalJSON(input []byte) error {
+	// the Seed struct has an embedded struct of `KnownSupply` with a custom UnmarshalJSON method,
+	// so we need to unmarshal it separately and then merge the results
+	if err := json.Unmarshal(input, &s.KnownSupply); err != nil {
+		return err
+	}
+
+	var dec struct {
+		Issuance *supply.Issuance `json:"issuance,omitempty"`
+		Burn     *supply.Burn     `json:"burn,omitempty"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	s.Issuance = dec.Issuance
+	s.Burn = dec.Burn
+
+	return nil
+}
+
+// SeedCategory is the category of the seed supply not covered by the component totals of the seed,
+// an issuance when the total supply is higher than their sum, a burn when it is lower
+const SeedCategory = "seed"
+
+// LoadSeed reads a seed from a JSON file
+func LoadSeed(path string) (Seed, error) {
+	var seed Seed
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return seed, fmt.Errorf("failed to read seed file: %v", err)
+	}
+	if err := json.Unmarshal(bytes, &seed); err != nil {
+		return seed, fmt.Errorf("failed to unmarshal seed file: %v", err)
+	}
+
+	return seed, nil
+}
+
+// NewSeededState returns a state starting at the seed block.
+// Entries up to the seed block are ignored, as they are accounted in the seed.
+func NewSeededState(seed Seed) (*State, error) {
+	if seed.Hash == (common.Hash{}) {
+		return nil, errors.New("seed block hash is required")
+	}
+	if seed.TotalSupply == nil {
+		return nil, errors.New("seed total supply is required")
+	}
+
+	state := NewState()
+	state.BlockNumber = seed.BlockNumber
+	state.Hash = seed.Hash
+
+	if seed.Issuance != nil {
+		setIfNotNil(state.Issuance.GenesisAlloc, seed.Issuance.GenesisAlloc)
+		setIfNotNil(state.Issuance.Reward, seed.Issuance.Reward)
+		setIfNotNil(state.Issuance.Withdrawals, seed.Issuance.Withdrawals)
+		state.Issuance.Other = supply.AddOther(nil, seed.Issuance.Other, false)
+	}
+	if seed.Burn != nil {
+		setIfNotNil(state.Burn.EIP1559, seed.Burn.EIP1559)
+		setIfNotNil(state.Burn.Blob, seed.Burn.Blob)
+		setIfNotNil(state.Burn.Misc, seed.Burn.Misc)
+		state.Burn.Other = supply.AddOther(nil, seed.Burn.Other, false)
+	}
+
+	// The supply not covered by the components is accounted in the seed category,
+	// so that the total supply stays the sum of the components
+	totals := supply.Info{Issuance: state.Issuance, Burn: state.Burn}
+	remainder := new(big.Int).Sub(seed.TotalSupply, totals.CalculatedDelta())
+	switch remainder.Sign() {
+	case 1:
+		state.Issuance.Other = supply.AddOther(state.Issuance.Other, map[string]*big.Int{SeedCategory: remainder}, false)
+	case -1:
+		state.Burn.Other = supply.AddOther(state.Burn.Other, map[string]*big.Int{SeedCategory: remainder.Neg(remainder)}, false)
+	}
+	state.Delta.Set(seed.TotalSupply)
+
+	state.canonicalChain[seed.BlockNumber] = seed.Hash
+	state.seed = &BlockRef{Number: seed.BlockNumber, Hash: seed.Hash}
+
+	// Keep the seed block in history, so that the state can be rewound to it.
+	// Its supply is accounted in the totals, so its entry has no delta.
+	entry := supply.New()
+	entry.Number = seed.BlockNumber
+	entry.Hash = seed.Hash
+	state.addToHistory(entry)
+
+	return state, nil
+}
+
+func setIfNotNil(dst, src *big.Int) {
+	if src != nil {
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,153 @@
+package tracker
+
+import (
+	"encoding/json"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+func TestSeedUnmarshalJSON(t *testing.T) {
+	var seed Seed
+	input := `{"blockNumber":5,"hash":"0x0500000000000000000000000000000000000000000000000000000000000000","totalSupply":"1000","issuance":{"reward":"0x2"},"burn":{"eip1559":"0x1"}}`
+	if err := json.Unmarshal([]byte(input), &seed); err != nil {
+		t.Fatal(err)
+	}
+
+	if seed.BlockNumber != 5 || seed.Hash != (common.Hash{5}) || seed.TotalSupply.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("unexpected seed block: %+v", seed.KnownSupply)
+	}
+	if seed.Issuance == nil || seed.Issuance.Reward.Cmp(big.NewInt(2)) != 0 || seed.Burn == nil || seed.Burn.EIP1559.Cmp(big1) != 0 {
+		t.Errorf("unexpected seed components: %+v %+v", seed.Issuance, seed.Burn)
+	}
+}
+
+func TestNewSeededState(t *testing.T) {
+	seed := Seed{KnownSupply: KnownSupply{BlockNumber: 5, Hash: common.Hash{5}, TotalSupply: big.NewInt(1000)}}
+	s, err := NewSeededState(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errCh := make(chan error, 16)
+
+	// Entries up to the seed block are ignored
+	for i := uint64(4); i <= 6; i++ {
+		block := newSupplyInfo()
+		block.Number = i
+		block.Issuance.Reward = big1
+		block.Hash = common.Hash{byte(i)}
+		block.ParentHash = common.Hash{byte(i - 1)}
+
+		s.HandleEntry(block, errCh)
+	}
+	if len(errCh) != 0 {
+		t.Fatalf("unexpected error: %v", <-errCh)
+	}
+	if s.BlockNumber != 6 || s.Delta.Cmp(big.NewInt(1001)) != 0 || s.Issuance.Reward.Cmp(big1) != 0 {
+		t.Errorf("want block 6 with total 1001, have block %d with total %s", s.BlockNumber, s.Delta)
+	}
+
+	// Reorg of the block after the seed block
+	block := newSupplyInfo()
+	block.Number = 6
+	block.Issuance.Reward = big.NewInt(2)
+	block.Hash = common.Hash{6, 1}
+	block.ParentHash = common.Hash{5}
+	s.HandleEntry(block, errCh)
+
+	if len(errCh) != 0 {
+		t.Fatalf("unexpected error: %v", <-errCh)
+	}
+	if s.Hash != (common.Hash{6, 1}) || s.Delta.Cmp(big.NewInt(1002)) != 0 {
+		t.Errorf("want block %s with total 1002, have block %s with total %s", common.Hash{6, 1}, s.Hash, s.Delta)
+	}
+
+	if seed := s.Issuance.Other[SeedCategory]; seed == nil || seed.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("want the seed supply 1000 as an issuance, have %v", seed)
+	}
+
+	if _, err := NewSeededState(Seed{KnownSupply: KnownSupply{BlockNumber: 5, TotalSupply: big1}}); err == nil {
+		t.Errorf("expected error for a seed without hash")
+	}
+}
+
+func TestSeedComponents(t *testing.T) {
+	for _, c := range []struct {
+		total, reward, burn int64
+		category            string
+		seed                int64
+	}{
+		{total: 100, reward: 70, burn: 10, category: "issuance", seed: 40},
+		{total: 50, reward: 70, burn: 10, category: "burn", seed: 10},
+		{total: 60, reward: 70, burn: 10},
+	} {
+		seed := Seed{
+			KnownSupply: KnownSupply{BlockNumber: 5, Hash: common.Hash{5}, TotalSupply: big.NewInt(c.total)},
+			Issuance:    &supply.Issuance{Reward: big.NewInt(c.reward)},
+			Burn:        &supply.Burn{EIP1559: big.NewInt(c.burn)},
+		}
+		s, err := NewSeededState(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The total supply is the sum of the components
+		totals := supply.Info{Issuance: s.Issuance, Burn: s.Burn}
+		if s.Delta.Int64() != c.total || totals.CalculatedDelta().Int64() != c.total {
+			t.Errorf("total %d: want it as the sum of the components, have %s and %s", c.total, s.Delta, totals.CalculatedDelta())
+		}
+
+		issuance, burn := s.Issuance.Other[SeedCategory], s.Burn.Other[SeedCategory]
+		switch c.category {
+		case "issuance":
+			if issuance == nil || issuance.Int64() != c.seed || burn != nil {
+				t.Errorf("total %d: want the seed issuance %d, have %v and burn %v", c.total, c.seed, issuance, burn)
+			}
+		case "burn":
+			if burn == nil || burn.Int64() != c.seed || issuance != nil {
+				t.Errorf("total %d: want the seed burn %d, have %v and issuance %v", c.total, c.seed, burn, issuance)
+			}
+		default:
+			if issuance != nil || burn != nil {
+				t.Errorf("total %d: want no seed category, have %v and %v", c.total, issuance, burn)
+			}
+		}
+	}
+}
+
+func TestSeedPersisted(t *testing.T) {
+	seed := Seed{KnownSupply: KnownSupply{BlockNumber: 5, Hash: common.Hash{5}, TotalSupply: big.NewInt(1000)}}
+	seeded, err := NewSeededState(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+	seeded.SaveState(path, "supply.jsonl")
+
+	s := NewState()
+	if _, err := s.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries up to the seed block are still ignored after a restart
+	errCh := make(chan error, 16)
+	for i := uint64(4); i <= 5; i++ {
+		block := newSupplyInfo()
+		block.Number = i
+		block.Issuance.Reward = big1
+		block.Hash = common.Hash{byte(i), 1}
+		block.ParentHash = common.Hash{byte(i - 1), 1}
+
+		s.HandleEntry(block, errCh)
+	}
+	if len(errCh) != 0 {
+		t.Fatalf("unexpected error: %v", <-errCh)
+	}
+	if s.BlockNumber != 5 || s.Hash != (common.Hash{5}) || s.Delta.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("want seed block 5 with total 1000, have block %d (%s) with total %s", s.BlockNumber, s.Hash, s.Delta)
+	}
+}
@@ -0,0 +1,236 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+// Interval is the duration of the buckets of a supply series
+type Interval string
+
+const (
+	Hour Interval = "hour"
+	Day  Interval = "day"
+	Week Interval = "week"
+)
+
+// Intervals are the intervals of the series kept by the state
+var Intervals = []Interval{Hour, Day, Week}
+
+// seriesLimits are the maximum number of buckets kept per interval
+var seriesLimits = map[Interval]int{
+	Hour: 24 * 31,  // a month
+	Day:  366 * 10, // ten years
+	Week: 53 * 20,  // twenty years
+}
+
+// ParseInterval returns the interval with the given name
+func ParseInterval(name string) (Interval, error) {
+	for _, interval := range Intervals {
+		if string(interval) == name {
+			return interval, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown interval %q, want one of hour, day, week", name)
+}
+
+// bucketStart returns the start of the bucket of the timestamp, in unix seconds.
+// Weeks start on Monday.
+func (i Interval) bucketStart(timestamp uint64) uint64 {
+	switch i {
+	case Hour:
+		return timestamp - timestamp%3600
+	case Day:
+		return timestamp - timestamp%86400
+	default:
+		// The unix epoch is on a Thursday
+		return timestamp - (timestamp+3*86400)%(7*86400)
+	}
+}
+
+// Bucket is the supply of the blocks with a timestamp within an interval
+type Bucket struct {
+	Start    uint64   `json:"start"` // Unix time of the start of the interval
+	Blocks   int      `json:"blocks"`
+	Issuance *big.Int `json:"issuance"`
+	Burn     *big.Int `json:"burn"`
+	Delta    *big.Int `json:"delta"`
+}
+
+func (b Bucket) MarshalJSON() ([]byte, error) {
+	type Alias Bucket
+	enc := struct {
+		Alias
+		Issuance *hexutil.Big `json:"issuance"`
+		Burn     *hexutil.Big `json:"burn"`
+		Delta    *hexutil.Big `json:"delta"`
+	}{
+		Alias:    (Alias)(b),
+		Issuance: (*hexutil.Big)(b.Issuance),
+		Burn:     (*hexutil.Big)(b.Burn),
+		Delta:    (*hexutil.Big)(b.Delta),
+	}
+
+	return json.Marshal(&enc)
+}
+
+func (b *Bucket) UnmarshalJSON(input []byte) error {
+	type Alias Bucket
+	dec := struct {
+		*Alias
+		Issuance json.RawMessage `json:"issuance"`
+		Burn     json.RawMessage `json:"burn"`
+		Delta    json.RawMessage `json:"delta"`
+	}{
+		Alias: (*Alias)(b),
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+
+	var err error
+	if b.Issuance, err = supply.ParseSignedBig(dec.Issuance); err != nil {
+		return fmt.Errorf("invalid issuance of bucket %d: %v", b.Start, err)
+	}
+	if b.Burn, err = supply.ParseSignedBig(dec.Burn); err != nil {
+		return fmt.Errorf("invalid burn of bucket %d: %v", b.Start, err)
+	}
+	if b.Delta, err = supply.ParseSignedBig(dec.Delta); err != nil {
+		return fmt.Errorf("invalid delta of bucket %d: %v", b.Start, err)
+	}
+
+	return nil
+}
+
+// Copy returns a deep copy of the bucket
+func (b *Bucket) Copy() Bucket {
+	return Bucket{
+		Start:    b.Start,
+		Blocks:   b.Blocks,
+		Issuance: new(big.Int).Set(b.Issuance),
+		Burn:     new(big.Int).Set(b.Burn),
+		Delta:    new(big.Int).Set(b.Delta),
+	}
+}
+
+// Series returns the buckets of the interval starting within [from, to], oldest first
+func (s *State) Series(interval Interval, from, to uint64) []Bucket {
+	s.RLock()
+	defer s.RUnlock()
+
+	buckets := make([]Bucket, 0)
+	for _, bucket := range s.series[interval] {
+		if bucket.Start >= from && bucket.Start <= to {
+			buckets = append(buckets, bucket.Copy())
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start < buckets[j].Start })
+
+	return buckets
+}
+
+// copySeries returns a copy of the buckets of all the intervals, oldest first.
+// It has to be called while holding the lock.
+func (s *State) copySeries() map[Interval][]Bucket {
+	if len(s.series) == 0 {
+		return nil
+	}
+
+	series := make(map[Interval][]Bucket, len(s.series))
+	for interval, buckets := range s.series {
+		for _, bucket := range buckets {
+			series[interval] = append(series[interval], bucket.Copy())
+		}
+		sort.Slice(series[interval], func(i, j int) bool { return series[interval][i].Start < series[interval][j].Start })
+	}
+
+	return series
+}
+
+// setSeries sets the buckets of all the intervals.
+// It has to be called while holding the lock.
+func (s *State) setSeries(series map[Interval][]Bucket) {
+	s.series = make(map[Interval]map[uint64]*Bucket, len(series))
+	for interval, buckets := range series {
+		s.series[interval] = make(map[uint64]*Bucket, len(buckets))
+		for i := range buckets {
+			s.series[interval][buckets[i].Start] = &buckets[i]
+		}
+	}
+}
+
+// addToSeries adds the supply data to the buckets of its timestamp, or subtracts it when sub is set.
+// Entries without a timestamp are not bucketed.
+// It has to be called while holding the lock.
+func (s *State) addToSeries(entry *supply.Info, sub bool) {
+	s.addToBuckets(entry, sub, 1)
+}
+
+// addToBuckets adds the supply data to the buckets of its timestamp as the number of blocks,
+// or subtracts it when sub is set. It has to be called while holding the lock.
+func (s *State) addToBuckets(entry *supply.Info, sub bool, blocks int) {
+	if entry.Timestamp == 0 {
+		return
+	}
+	if s.series == nil {
+		s.series = make(map[Interval]map[uint64]*Bucket, len(Intervals))
+	}
+
+	for _, interval := range Intervals {
+		buckets := s.series[interval]
+		if buckets == nil {
+			buckets = make(map[uint64]*Bucket)
+			s.series[interval] = buckets
+		}
+
+		start := interval.bucketStart(entry.Timestamp)
+		bucket := buckets[start]
+		if bucket == nil {
+			if sub {
+				continue
+			}
+			bucket = &Bucket{Start: start, Issuance: new(big.Int), Burn: new(big.Int), Delta: new(big.Int)}
+			buckets[start] = bucket
+			trimSeries(buckets, seriesLimits[interval])
+		}
+
+		// Accumulate in place, so that no block allocates once its buckets exist
+		entry.Issuance.AddTo(bucket.Issuance, sub)
+		entry.Burn.AddTo(bucket.Burn, sub)
+		entry.AddDeltaTo(bucket.Delta, sub)
+		if !sub {
+			bucket.Blocks += blocks
+			continue
+		}
+		bucket.Blocks -= blocks
+		if bucket.empty() {
+			delete(buckets, start)
+		}
+	}
+}
+
+// empty returns whether the bucket has no blocks and no amounts, e.g. of an anchor
+func (b *Bucket) empty() bool {
+	return b.Blocks <= 0 && b.Issuance.Sign() == 0 && b.Burn.Sign() == 0 && b.Delta.Sign() == 0
+}
+
+// trimSeries deletes the oldest buckets over the limit
+func trimSeries(buckets map[uint64]*Bucket, limit int) {
+	for len(buckets) > limit {
+		oldest := uint64(0)
+		first := true
+		for start := range buckets {
+			if first || start < oldest {
+				oldest = start
+				first = false
+			}
+		}
+		delete(buckets, oldest)
+	}
+}
@@ -0,0 +1,97 @@
+package tracker
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+)
+
+func TestBucketStart(t *testing.T) {
+	// Wednesday, 12 April 2023 22:27:35 UTC
+	timestamp := uint64(1681338455)
+
+	tests := []struct {
+		interval Interval
+		want     uint64
+	}{
+		{Hour, 1681336800}, // 22:00
+		{Day, 1681257600},  // 12 April 00:00
+		{Week, 1681084800}, // Monday, 10 April 00:00
+	}
+	for _, tt := range tests {
+		if have := tt.interval.bucketStart(timestamp); have != tt.want {
+			t.Errorf("%s: want bucket start %d, have %d", tt.interval, tt.want, have)
+		}
+	}
+}
+
+func TestSeries(t *testing.T) {
+	s := NewState()
+
+	entry := newSupplyInfo()
+	entry.Timestamp = 1681338455
+	entry.Issuance.Reward = big.NewInt(3)
+	entry.Burn.EIP1559 = big1
+	s.add(&entry)
+	s.add(&entry)
+
+	// Entries without a timestamp are not bucketed
+	untimed := newSupplyInfo()
+	untimed.Issuance.Reward = big1
+	s.add(&untimed)
+
+	buckets := s.Series(Day, 0, 1681338455)
+	if len(buckets) != 1 || buckets[0].Start != 1681257600 || buckets[0].Blocks != 2 {
+		t.Fatalf("unexpected buckets: %+v", buckets)
+	}
+	if buckets[0].Issuance.Int64() != 6 || buckets[0].Burn.Int64() != 2 || buckets[0].Delta.Int64() != 4 {
+		t.Errorf("unexpected bucket totals: %+v", buckets[0])
+	}
+	if buckets := s.Series(Hour, 1681336801, 1681340000); len(buckets) != 0 {
+		t.Errorf("want no buckets out of range, have %+v", buckets)
+	}
+
+	// The series are persisted
+	path := filepath.Join(t.TempDir(), "state.json")
+	s.SaveState(path, "supply.jsonl")
+	loaded := NewState()
+	if _, err := loaded.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	if buckets := loaded.Series(Week, 0, 1681338455); len(buckets) != 1 || buckets[0].Delta.Int64() != 4 {
+		t.Errorf("unexpected loaded buckets: %+v", buckets)
+	}
+
+	// Reverted blocks are subtracted, and empty buckets deleted
+	loaded.sub(&entry)
+	loaded.sub(&entry)
+	if buckets := loaded.Series(Week, 0, 1681338455); len(buckets) != 0 {
+		t.Errorf("want no buckets after reverting all blocks, have %+v", buckets)
+	}
+}
+
+func TestSeriesKeepsAmountsWithoutBlocks(t *testing.T) {
+	s := NewState()
+
+	entry := newSupplyInfo()
+	entry.Timestamp = 1681338455
+	entry.Issuance.Reward = big.NewInt(2)
+	s.add(&entry)
+
+	// An adjustment in the bucket, without a block, outlives the reorg of the block
+	adjustment := newSupplyInfo()
+	adjustment.Timestamp = entry.Timestamp
+	adjustment.Issuance.Other = map[string]*big.Int{AnchorCategory: big.NewInt(5)}
+	s.addToBuckets(&adjustment, false, 0)
+	s.sub(&entry)
+
+	buckets := s.Series(Hour, 0, entry.Timestamp)
+	if len(buckets) != 1 || buckets[0].Blocks != 0 || buckets[0].Issuance.Int64() != 5 || buckets[0].Delta.Int64() != 5 {
+		t.Errorf("want the bucket of the adjustment kept, have %+v", buckets)
+	}
+
+	s.addToBuckets(&adjustment, true, 0)
+	if buckets := s.Series(Hour, 0, entry.Timestamp); len(buckets) != 0 {
+		t.Errorf("want the empty bucket deleted, have %+v", buckets)
+	}
+}
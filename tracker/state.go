@@ -1,4 +1,6 @@
-package main
+// Package tracker keeps track of the total supply, by summing the supply data
+// of the canonical chain and handling chain reorgs.
+package tracker
 
 import (
 	"encoding/json"
@@ -11,24 +13,38 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	orderedmap "github.com/wk8/go-ordered-map/v2"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
 )
 
-// historyLimit is the maximum number of blocks to keep in history
-const historyLimit = 1024
+// DefaultHistoryLimit is the default maximum number of blocks to keep in history, see SetHistoryLimit
+const DefaultHistoryLimit = 1024
 
-// totalSupply represents the total supply data
-type totalSupply struct {
+// maxSpareHashes is the maximum number of maps of the blocks cleaned from history kept for reuse
+const maxSpareHashes = 16
+
+// TotalSupply represents the total supply data
+type TotalSupply struct {
 	BlockNumber uint64      `json:"blockNumber"` // Block number of the current state
 	Hash        common.Hash `json:"hash"`        // Hash of the current state
 	ParentHash  common.Hash `json:"parentHash"`  // Parent hash of the current state
 
-	Delta    *big.Int            `json:"delta"`
-	Issuance *supplyInfoIssuance `json:"issuance,omitempty"`
-	Burn     *supplyInfoBurn     `json:"burn,omitempty"`
+	Delta    *big.Int         `json:"delta"`
+	Issuance *supply.Issuance `json:"issuance,omitempty"`
+	Burn     *supply.Burn     `json:"burn,omitempty"`
 }
 
-func (s totalSupply) MarshalJSON() ([]byte, error) {
-	type Alias totalSupply
+// Copy returns a deep copy of the total supply
+func (s TotalSupply) Copy() TotalSupply {
+	cpy := s
+	cpy.Delta = new(big.Int).Set(s.Delta)
+	cpy.Issuance = s.Issuance.Copy()
+	cpy.Burn = s.Burn.Copy()
+
+	return cpy
+}
+
+func (s TotalSupply) MarshalJSON() ([]byte, error) {
+	type Alias TotalSupply
 	enc := struct {
 		Alias
 		Delta     *hexutil.Big `json:"delta"`
@@ -50,12 +66,14 @@ func (s totalSupply) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&enc)
 }
 
-func (s *totalSupply) UnmarshalJSON(input []byte) error {
-	type Alias totalSupply
+// UnmarshalJSON unmarshals both schema versions of the total supply,
+// the absolute delta with a `deltaSign` of version 1 and the signed delta of version 2
+func (s *TotalSupply) UnmarshalJSON(input []byte) error {
+	type Alias TotalSupply
 	dec := struct {
 		*Alias
-		Delta     *hexutil.Big `json:"delta"`
-		DeltaSign string       `json:"deltaSign"`
+		Delta     json.RawMessage `json:"delta"`
+		DeltaSign string          `json:"deltaSign"`
 	}{
 		Alias: (*Alias)(s),
 	}
@@ -63,158 +81,168 @@ func (s *totalSupply) UnmarshalJSON(input []byte) error {
 		return err
 	}
 
-	if dec.Delta != nil && dec.DeltaSign == "-" {
-		delta := (*big.Int)(dec.Delta)
-		s.Delta = delta.Neg(delta)
+	if len(dec.Delta) > 0 && string(dec.Delta) != "null" {
+		delta, err := supply.ParseSignedBig(dec.Delta)
+		if err != nil {
+			return fmt.Errorf("invalid delta: %v", err)
+		}
+		if dec.DeltaSign == "-" && delta.Sign() > 0 {
+			delta.Neg(delta)
+		}
+		s.Delta = delta
 	}
 
 	return nil
 }
 
-// State represents the latest state of the parsed supply data
-type State struct {
-	totalSupply
-
-	sync.RWMutex
-
-	canonicalChain map[uint64]common.Hash
-	HashHistory    *orderedmap.OrderedMap[uint64, map[common.Hash]supplyInfo] `json:"-"`
-}
-
-type PersistedState struct {
-	totalSupply
-	File string `json:"file"`
-}
+// SignedTotalSupply marshals the total supply as in schema version 2,
+// with a signed hex delta, e.g. "-0x1", instead of a `deltaSign`
+type SignedTotalSupply TotalSupply
 
-func (ps PersistedState) MarshalJSON() ([]byte, error) {
-	type Alias PersistedState
+func (s SignedTotalSupply) MarshalJSON() ([]byte, error) {
+	type Alias TotalSupply
 	enc := struct {
 		Alias
+		Delta *hexutil.Big `json:"delta"`
 	}{
-		Alias: (Alias)(ps),
+		Alias: (Alias)(s),
+		Delta: (*hexutil.Big)(s.Delta),
 	}
 
-	// the PersistedState struct has an embedded struct of `totalSupply` with a custom MarshalJSON method,
-	// so we need to marshal it separately and then merge the results
+	return json.Marshal(&enc)
+}
 
-	// marshal the embedded struct of `totalSupply`
-	s, err := json.Marshal(&enc)
-	if err != nil {
-		return nil, err
-	}
+// State represents the latest state of the parsed supply data
+type State struct {
+	TotalSupply
 
-	// unmarshal the embedded struct of `totalSupply` to a map
-	var data map[string]interface{}
-	if err := json.Unmarshal(s, &data); err != nil {
-		return nil, err
-	}
-	// add the `file` field
-	data["file"] = ps.File
+	sync.RWMutex
 
-	return json.Marshal(&data)
-}
+	canonicalChain map[uint64]common.Hash
+	HashHistory    *orderedmap.OrderedMap[uint64, map[common.Hash]supply.Info] `json:"-"`
+	historyLimit   int                                                         // Maximum number of blocks in history, the deepest reorg that can be handled
+	spareHashes    []map[common.Hash]supply.Info                               // Maps of the blocks cleaned from history, see recycleHashes
 
-func (s *PersistedState) UnmarshalJSON(input []byte) error {
-	type Alias PersistedState
-	dec := struct {
-		*Alias
-	}{
-		Alias: (*Alias)(s),
-	}
+	hooks   []*Hooks
+	hooksMu sync.Mutex
 
-	// the PersistedState struct has an embedded struct of `totalSupply` with a custom UnmarshalJSON method,
-	// so we need to unmarshal it separately and then merge the results
-	if err := json.Unmarshal(input, &dec); err != nil {
-		return err
-	}
+	reverted int // Number of canonical blocks reverted while handling the current entry
 
-	var data map[string]interface{}
-	err := json.Unmarshal(input, &data)
-	if err != nil {
-		return err
-	}
-	if data["file"] != nil {
-		s.File = data["file"].(string)
-	}
+	seed *BlockRef // Starting block of a seeded state, entries up to it are ignored
 
-	return nil
-}
+	eraTotals []*EraTotal // Component totals partitioned by era, see SetEras
 
-type SaveLastParsedFile string
+	series map[Interval]map[uint64]*Bucket // Buckets of the block timestamps by interval and start
 
+	headTimestamp uint64 // Unix time of the head, zero if unknown
+}
+
+// NewState returns an empty state
 func NewState() *State {
 	state := &State{}
 	state.Delta = big.NewInt(0)
-	state.Issuance = &supplyInfoIssuance{
+	state.Issuance = &supply.Issuance{
 		GenesisAlloc: big.NewInt(0),
 		Reward:       big.NewInt(0),
 		Withdrawals:  big.NewInt(0),
 	}
-	state.Burn = &supplyInfoBurn{
+	state.Burn = &supply.Burn{
 		EIP1559: big.NewInt(0),
 		Blob:    big.NewInt(0),
 		Misc:    big.NewInt(0),
 	}
 
 	state.canonicalChain = make(map[uint64]common.Hash)
-	state.HashHistory = orderedmap.New[uint64, map[common.Hash]supplyInfo](historyLimit)
+	state.HashHistory = orderedmap.New[uint64, map[common.Hash]supply.Info](DefaultHistoryLimit)
+	state.historyLimit = DefaultHistoryLimit
 
 	return state
 }
 
+// SetHistoryLimit sets the maximum number of blocks to keep in history.
+// Reorgs deeper than the limit cannot be handled.
+func (s *State) SetHistoryLimit(limit int) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.historyLimit = limit
+}
+
+// Snapshot returns a copy of the current total supply,
+// which can be used without holding the lock of the state
+func (s *State) Snapshot() TotalSupply {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.TotalSupply.Copy()
+}
+
 // setHead sets the current block as the state head
-func (s *State) setHead(supply *supplyInfo) {
+func (s *State) setHead(entry *supply.Info) {
 	s.Lock()
 	defer s.Unlock()
 
 	// Set current block as state head
-	s.BlockNumber = supply.Number
-	s.Hash = supply.Hash
-	s.ParentHash = supply.ParentHash
+	s.BlockNumber = entry.Number
+	s.Hash = entry.Hash
+	s.ParentHash = entry.ParentHash
+	s.headTimestamp = entry.Timestamp
 
-	s.canonicalChain[supply.Number] = supply.Hash
+	s.canonicalChain[entry.Number] = entry.Hash
 }
 
 // add adds the supply data to the state
-func (s *State) add(supply *supplyInfo) {
+func (s *State) add(entry *supply.Info) {
 	s.Lock()
 	defer s.Unlock()
 
-	s.Issuance.GenesisAlloc.Add(s.Issuance.GenesisAlloc, supply.Issuance.GenesisAlloc)
-	s.Issuance.Reward.Add(s.Issuance.Reward, supply.Issuance.Reward)
-	s.Issuance.Withdrawals.Add(s.Issuance.Withdrawals, supply.Issuance.Withdrawals)
-	s.Burn.EIP1559.Add(s.Burn.EIP1559, supply.Burn.EIP1559)
-	s.Burn.Blob.Add(s.Burn.Blob, supply.Burn.Blob)
-	s.Burn.Misc.Add(s.Burn.Misc, supply.Burn.Misc)
-
-	delta := supply.getCalculatedDelta()
-	s.Delta.Add(s.Delta, delta)
+	s.Issuance.GenesisAlloc.Add(s.Issuance.GenesisAlloc, entry.Issuance.GenesisAlloc)
+	s.Issuance.Reward.Add(s.Issuance.Reward, entry.Issuance.Reward)
+	s.Issuance.Withdrawals.Add(s.Issuance.Withdrawals, entry.Issuance.Withdrawals)
+	s.Burn.EIP1559.Add(s.Burn.EIP1559, entry.Burn.EIP1559)
+	s.Burn.Blob.Add(s.Burn.Blob, entry.Burn.Blob)
+	s.Burn.Misc.Add(s.Burn.Misc, entry.Burn.Misc)
+	s.Issuance.Other = supply.AddOther(s.Issuance.Other, entry.Issuance.Other, false)
+	s.Burn.Other = supply.AddOther(s.Burn.Other, entry.Burn.Other, false)
+	s.addToEra(entry, false)
+	s.addToSeries(entry, false)
+
+	entry.AddDeltaTo(s.Delta, false)
 }
 
 // sub subtracts the supply data from the state
-func (s *State) sub(supply *supplyInfo) {
+func (s *State) sub(entry *supply.Info) {
 	s.Lock()
 	defer s.Unlock()
 
-	s.Issuance.GenesisAlloc.Sub(s.Issuance.GenesisAlloc, supply.Issuance.GenesisAlloc)
-	s.Issuance.Reward.Sub(s.Issuance.Reward, supply.Issuance.Reward)
-	s.Issuance.Withdrawals.Sub(s.Issuance.Withdrawals, supply.Issuance.Withdrawals)
-	s.Burn.EIP1559.Sub(s.Burn.EIP1559, supply.Burn.EIP1559)
-	s.Burn.Blob.Sub(s.Burn.Blob, supply.Burn.Blob)
-	s.Burn.Misc.Sub(s.Burn.Misc, supply.Burn.Misc)
-
-	delta := supply.getCalculatedDelta()
-	s.Delta.Sub(s.Delta, delta)
+	s.Issuance.GenesisAlloc.Sub(s.Issuance.GenesisAlloc, entry.Issuance.GenesisAlloc)
+	s.Issuance.Reward.Sub(s.Issuance.Reward, entry.Issuance.Reward)
+	s.Issuance.Withdrawals.Sub(s.Issuance.Withdrawals, entry.Issuance.Withdrawals)
+	s.Burn.EIP1559.Sub(s.Burn.EIP1559, entry.Burn.EIP1559)
+	s.Burn.Blob.Sub(s.Burn.Blob, entry.Burn.Blob)
+	s.Burn.Misc.Sub(s.Burn.Misc, entry.Burn.Misc)
+	s.Issuance.Other = supply.AddOther(s.Issuance.Other, entry.Issuance.Other, true)
+	s.Burn.Other = supply.AddOther(s.Burn.Other, entry.Burn.Other, true)
+	s.addToEra(entry, true)
+	s.addToSeries(entry, true)
+
+	entry.AddDeltaTo(s.Delta, true)
 }
 
 // addToHistory adds the supply data to the history
-func (s *State) addToHistory(entry supplyInfo) {
+func (s *State) addToHistory(entry supply.Info) {
 	s.Lock()
 	defer s.Unlock()
 
 	hashes, exists := s.HashHistory.Get(entry.Number)
 	if !exists {
-		hashes = make(map[common.Hash]supplyInfo)
+		// Reuse a map of the blocks cleaned from history
+		if n := len(s.spareHashes); n > 0 {
+			hashes = s.spareHashes[n-1]
+			s.spareHashes = s.spareHashes[:n-1]
+		} else {
+			hashes = make(map[common.Hash]supply.Info)
+		}
 		s.HashHistory.Set(entry.Number, hashes)
 	}
 
@@ -224,7 +252,7 @@ func (s *State) addToHistory(entry supplyInfo) {
 }
 
 // getSupply returns the supply data for the specified block number and hash
-func (s *State) getSupply(hash common.Hash, number uint64) (*supplyInfo, bool) {
+func (s *State) getSupply(hash common.Hash, number uint64) (*supply.Info, bool) {
 	s.RLock()
 	defer s.RUnlock()
 
@@ -233,9 +261,9 @@ func (s *State) getSupply(hash common.Hash, number uint64) (*supplyInfo, bool) {
 		return nil, false
 	}
 
-	for hHash, supply := range hashes {
+	for hHash, entry := range hashes {
 		if hHash == hash {
-			return &supply, true
+			return &entry, true
 		}
 	}
 
@@ -243,7 +271,7 @@ func (s *State) getSupply(hash common.Hash, number uint64) (*supplyInfo, bool) {
 }
 
 // getSupplyByHash returns the supply data for the specified block hash
-func (s *State) getSupplyByHash(hash common.Hash) (*supplyInfo, bool) {
+func (s *State) getSupplyByHash(hash common.Hash) (*supply.Info, bool) {
 	s.RLock()
 	defer s.RUnlock()
 
@@ -252,9 +280,9 @@ func (s *State) getSupplyByHash(hash common.Hash) (*supplyInfo, bool) {
 
 	for pair := s.HashHistory.Newest(); pair != nil; pair = pair.Prev() {
 		hashes := pair.Value
-		for hHash, supply := range hashes {
+		for hHash, entry := range hashes {
 			if hHash == hash {
-				return &supply, true
+				return &entry, true
 			}
 		}
 	}
@@ -267,15 +295,16 @@ func (s *State) cleanHistory() {
 	s.Lock()
 	defer s.Unlock()
 
-	var pairToDelete *orderedmap.Pair[uint64, map[common.Hash]supplyInfo]
+	var pairToDelete *orderedmap.Pair[uint64, map[common.Hash]supply.Info]
 
 	for pair := s.HashHistory.Oldest(); pair != nil; pair = pair.Next() {
-		if s.HashHistory.Len() <= historyLimit {
+		if s.HashHistory.Len() <= s.historyLimit {
 			break
 		}
 
 		// Delete previous loop pair
 		if pairToDelete != nil {
+			s.recycleHashes(pairToDelete.Value)
 			s.HashHistory.Delete(pairToDelete.Key)
 		}
 
@@ -283,46 +312,74 @@ func (s *State) cleanHistory() {
 	}
 }
 
-// handleEntry updates the state with the new supply data.
-func (s *State) handleEntry(supply supplyInfo, errCh chan error) {
+// recycleHashes keeps the map of a block cleaned from history, to be reused by addToHistory
+func (s *State) recycleHashes(hashes map[common.Hash]supply.Info) {
+	if len(s.spareHashes) >= maxSpareHashes {
+		return
+	}
+	clear(hashes)
+	s.spareHashes = append(s.spareHashes, hashes)
+}
+
+// HandleEntry updates the state with the new supply data.
+func (s *State) HandleEntry(entry supply.Info, errCh chan error) {
+	// Entries up to the seed block are accounted in the seed
+	if s.seed != nil && entry.Number <= s.seed.Number {
+		return
+	}
+
+	oldHead := s.head()
+	s.reverted = 0
+
 	isInitialBlockHandling := s.BlockNumber == 0 && s.Hash == common.Hash{}
 
 	if !isInitialBlockHandling {
 		// When state is behind, forward to block parent
-		if supply.Number-1 > s.BlockNumber {
-			s.forwardTo(supply.Number-1, supply.ParentHash, errCh)
+		if entry.Number-1 > s.BlockNumber {
+			s.forwardTo(entry.Number-1, entry.ParentHash, errCh)
 
 			// When state is ahead or parent is not correct, rewind back
-		} else if supply.Number <= s.BlockNumber || supply.ParentHash != s.Hash {
+		} else if entry.Number <= s.BlockNumber || entry.ParentHash != s.Hash {
 
 			// Rewind to parent
-			blockNumberHint := supply.Number - 1
+			blockNumberHint := entry.Number - 1
 
 			// If the parent is not correct, then rewind by hash only
-			if supply.ParentHash != s.Hash {
+			if entry.ParentHash != s.Hash {
 				blockNumberHint = 0
 			}
 
-			s.rewindTo(supply.ParentHash, blockNumberHint, errCh)
+			s.rewindTo(entry.ParentHash, blockNumberHint, errCh)
 		}
 
 		// TODO: the validation happens after the chain reorgs to prepare the state for the new block.
 		// Do we want to revert the reorg in case the validation fails?
-		if supply.Number-1 != s.BlockNumber || supply.ParentHash != s.Hash {
-			errCh <- fmt.Errorf("skipping block %d entry. ParentHash check failed.\n\tCurrent %d ParentHash:\t%s\n\tParent %d Hash:\t%s", supply.Number, supply.Number, supply.ParentHash, s.BlockNumber, s.Hash)
+		if entry.Number-1 != s.BlockNumber || entry.ParentHash != s.Hash {
+			err := fmt.Errorf("skipping block %d entry. ParentHash check failed.\n\tCurrent %d ParentHash:\t%s\n\tParent %d Hash:\t%s", entry.Number, entry.Number, entry.ParentHash, s.BlockNumber, s.Hash)
+			s.notifyInvalidEntry(entry, err)
+			errCh <- err
 			return
 		}
 	}
 
 	// Update state
-	s.setHead(&supply)
-	s.add(&supply)
+	s.setHead(&entry)
+	s.add(&entry)
 
 	// Prepend current block to history for potential future rewinds.
-	s.addToHistory(supply)
+	s.addToHistory(entry)
 
 	// Clean history to maintain only recent blocks
 	s.cleanHistory()
+
+	s.notifyHead(entry)
+	if s.reverted > 0 {
+		s.notifyReorg(Reorg{
+			OldHead: oldHead,
+			NewHead: BlockRef{Number: entry.Number, Hash: entry.Hash},
+			Depth:   s.reverted,
+		})
+	}
 }
 
 // rewindTo rewinds the state to the specified block number and hash
@@ -330,9 +387,15 @@ func (s *State) rewindTo(hash common.Hash, numberHint uint64, errCh chan error)
 	// log.Println("Rewinding \n\tto number", numberHint, "hash", hash, "\n\tfrom number", s.BlockNumber, "hash", s.Hash)
 
 	fromBlock := s.BlockNumber
+	fromHead := s.head()
 	newestTrace := s.HashHistory.Newest()
 	oldestTrace := s.HashHistory.Oldest()
 
+	if newestTrace == nil {
+		errCh <- fmt.Errorf("cannot rewind to block hash %s, history is empty", hash)
+		return
+	}
+
 	number := uint64(0)
 
 	// Set number and hash of block to rewind to
@@ -385,14 +448,14 @@ func (s *State) rewindTo(hash common.Hash, numberHint uint64, errCh chan error)
 			return
 		}
 
-		supply, found := s.getSupply(hHash, hNumber)
+		entry, found := s.getSupply(hHash, hNumber)
 		if !found {
 			errCh <- fmt.Errorf("cannot find supply info for block number %d (%s)", hNumber, hHash)
 			return
 		}
 
 		// Set current state to the block we are aiming to rewind to
-		s.setHead(supply)
+		s.setHead(entry)
 
 		// Rewinded successfully, don't reverse last block totals
 		if hNumber == number {
@@ -400,23 +463,32 @@ func (s *State) rewindTo(hash common.Hash, numberHint uint64, errCh chan error)
 		}
 
 		// Reverse totals, skip the block we are rewinding to
-		s.sub(supply)
+		s.sub(entry)
 
 		depth++
+		s.reverted++
 	}
 
 	if depth > 3 {
 		log.Println("Rewinded successfully to block", hNumber, "from block", fromBlock, "depth", depth)
 	}
+
+	s.notifyRewind(fromHead, s.head(), depth)
 }
 
 // forwardTo forwards the state to the specified block number and hash
 func (s *State) forwardTo(number uint64, hash common.Hash, errCh chan error) {
 	// log.Println("Forwarding \n\tto number", number, "hash", hash, "\n\tfrom number", s.BlockNumber, "hash", s.Hash)
 
+	fromHead := s.head()
 	newestTrace := s.HashHistory.Newest()
 	oldestTrace := s.HashHistory.Oldest()
 
+	if newestTrace == nil {
+		errCh <- fmt.Errorf("cannot forward to block %d, history is empty", number)
+		return
+	}
+
 	// Check if the block to forward to is in history
 	if newestTrace.Key < number || oldestTrace.Key >= number {
 		errCh <- fmt.Errorf("cannot forward to block %d, it is not in history. History oldest number: %d, newest number: %d", number, oldestTrace.Key, newestTrace.Key)
@@ -427,9 +499,9 @@ func (s *State) forwardTo(number uint64, hash common.Hash, errCh chan error) {
 	lookupHash := hash
 	breakLoop := false
 
-	var pair *orderedmap.Pair[uint64, map[common.Hash]supplyInfo]
+	var pair *orderedmap.Pair[uint64, map[common.Hash]supply.Info]
 
-	forwardedChain := []supplyInfo{}
+	forwardedChain := []supply.Info{}
 
 	// Locate the block in history
 	for pair = s.HashHistory.Newest(); pair != nil; pair = pair.Prev() {
@@ -440,7 +512,7 @@ func (s *State) forwardTo(number uint64, hash common.Hash, errCh chan error) {
 			continue
 		}
 
-		supply, found := hashes[lookupHash]
+		entry, found := hashes[lookupHash]
 		if !found {
 			errCh <- fmt.Errorf("cannot find hash %s in history for block %d", lookupHash, hNumber)
 			return
@@ -454,24 +526,24 @@ func (s *State) forwardTo(number uint64, hash common.Hash, errCh chan error) {
 		}
 
 		// Next block lookupHash
-		lookupHash = supply.ParentHash
+		lookupHash = entry.ParentHash
 
 		if breakLoop {
 			break
 		}
 
-		forwardedChain = append([]supplyInfo{supply}, forwardedChain...)
+		forwardedChain = append([]supply.Info{entry}, forwardedChain...)
 	}
 
 	// Forward the state up to block
-	for _, supply := range forwardedChain {
-		if s.BlockNumber >= supply.Number {
-			s.rewindTo(supply.ParentHash, supply.Number-1, errCh)
+	for _, entry := range forwardedChain {
+		if s.BlockNumber >= entry.Number {
+			s.rewindTo(entry.ParentHash, entry.Number-1, errCh)
 		}
 
 		// Set current state
-		s.setHead(&supply)
-		s.add(&supply)
+		s.setHead(&entry)
+		s.add(&entry)
 	}
 
 	if s.BlockNumber != number {
@@ -482,6 +554,8 @@ func (s *State) forwardTo(number uint64, hash common.Hash, errCh chan error) {
 	if len(forwardedChain) > 3 {
 		log.Println("Forwarded successfully to block", number, "from block", forwardedChain[0].Number, "depth", len(forwardedChain))
 	}
+
+	s.notifyForward(fromHead, s.head(), len(forwardedChain))
 }
 
 // SaveState saves the current state to a file
@@ -489,14 +563,21 @@ func (s *State) SaveState(path, lastParsedFilename string) {
 	s.RLock()
 
 	ps := PersistedState{
-		totalSupply: s.totalSupply,
+		TotalSupply: s.TotalSupply,
 		File:        lastParsedFilename,
+		Eras:        s.copyEraTotals(),
+		Series:      s.copySeries(),
+	}
+	if s.seed != nil {
+		seed := *s.seed
+		ps.Seed = &seed
 	}
 
 	jsonData, err := json.Marshal(&ps)
 	if err != nil {
 		log.Fatalf("failed to marshal state: %v", err)
 	}
+	total := s.TotalSupply.Copy()
 
 	s.RUnlock()
 
@@ -504,6 +585,8 @@ func (s *State) SaveState(path, lastParsedFilename string) {
 	if err != nil {
 		log.Fatalf("failed to write state to file: %v", err)
 	}
+
+	s.notifyCheckpoint(path, lastParsedFilename, total)
 }
 
 // LoadState loads the state from a file
@@ -516,9 +599,19 @@ func (s *State) LoadState(file string) (lastFile string, err error) {
 	var ps PersistedState
 	err = json.Unmarshal(bytes, &ps)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal state file: %v", err)
+		return "", fmt.Errorf("failed to unmarshal state file: %w", err)
+	}
+	s.TotalSupply = ps.TotalSupply
+	s.eraTotals = nil
+	for i := range ps.Eras {
+		s.eraTotals = append(s.eraTotals, &ps.Eras[i])
+	}
+	s.setSeries(ps.Series)
+	s.seed = ps.Seed
+
+	if ps.Version < LatestSchema {
+		log.Printf("Migrated state file '%s' from schema version %d to %d, it is written in version %d on the next save.", file, ps.Version, LatestSchema, LatestSchema)
 	}
-	s.totalSupply = ps.totalSupply
 
 	log.Printf("Loaded state from file '%s'. Last parsed file from logs is '%s'.", file, ps.File)
 
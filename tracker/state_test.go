@@ -1,4 +1,4 @@
-package main
+package tracker
 
 import (
 	"fmt"
@@ -8,6 +8,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	orderedmap "github.com/wk8/go-ordered-map/v2"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
 )
 
 var (
@@ -15,32 +16,20 @@ var (
 	big1 = big.NewInt(1)
 )
 
-func newSupplyInfo() supplyInfo {
-	s := supplyInfo{}
-	s.Delta = big.NewInt(0)
-	s.Issuance = &supplyInfoIssuance{
-		GenesisAlloc: big.NewInt(0),
-		Reward:       big.NewInt(0),
-		Withdrawals:  big.NewInt(0),
-	}
-	s.Burn = &supplyInfoBurn{
-		EIP1559: big.NewInt(0),
-		Blob:    big.NewInt(0),
-		Misc:    big.NewInt(0),
-	}
-	return s
+func newSupplyInfo() supply.Info {
+	return supply.New()
 }
 
 func TestSetHead(t *testing.T) {
 	s := NewState()
 
-	supply := supplyInfo{
+	entry := supply.Info{
 		Number:     10,
 		Hash:       common.Hash{10},
 		ParentHash: common.Hash{9},
 	}
 
-	s.setHead(&supply)
+	s.setHead(&entry)
 
 	if s.BlockNumber != 10 || s.Hash.Cmp(common.Hash{10}) != 0 || s.ParentHash.Cmp(common.Hash{9}) != 0 {
 		t.Errorf("setHead failed to update state variables")
@@ -58,18 +47,18 @@ func TestAdd(t *testing.T) {
 	s.Burn.Misc = big.NewInt(9)
 
 	// Add a new supply
-	supply := newSupplyInfo()
-	supply.Number = 10
-	supply.Hash = common.Hash{10}
-	supply.ParentHash = common.Hash{9}
-	supply.Issuance.GenesisAlloc = big1
-	supply.Issuance.Reward = big1
-	supply.Issuance.Withdrawals = big1
-	supply.Burn.EIP1559 = big1
-	supply.Burn.Blob = big1
-	supply.Burn.Misc = big1
-
-	s.add(&supply)
+	entry := newSupplyInfo()
+	entry.Number = 10
+	entry.Hash = common.Hash{10}
+	entry.ParentHash = common.Hash{9}
+	entry.Issuance.GenesisAlloc = big1
+	entry.Issuance.Reward = big1
+	entry.Issuance.Withdrawals = big1
+	entry.Burn.EIP1559 = big1
+	entry.Burn.Blob = big1
+	entry.Burn.Misc = big1
+
+	s.add(&entry)
 
 	big10 := big.NewInt(10)
 
@@ -96,18 +85,18 @@ func TestSub(t *testing.T) {
 	s.Burn.Blob = big.NewInt(9)
 	s.Burn.Misc = big.NewInt(9)
 
-	supply := newSupplyInfo()
-	supply.Number = 10
-	supply.Hash = common.Hash{10}
-	supply.ParentHash = common.Hash{9}
-	supply.Issuance.GenesisAlloc = big1
-	supply.Issuance.Reward = big1
-	supply.Issuance.Withdrawals = big1
-	supply.Burn.EIP1559 = big1
-	supply.Burn.Blob = big1
-	supply.Burn.Misc = big1
+	entry := newSupplyInfo()
+	entry.Number = 10
+	entry.Hash = common.Hash{10}
+	entry.ParentHash = common.Hash{9}
+	entry.Issuance.GenesisAlloc = big1
+	entry.Issuance.Reward = big1
+	entry.Issuance.Withdrawals = big1
+	entry.Burn.EIP1559 = big1
+	entry.Burn.Blob = big1
+	entry.Burn.Misc = big1
 
-	s.sub(&supply)
+	s.sub(&entry)
 
 	big8 := big.NewInt(8)
 
@@ -167,7 +156,7 @@ func TestCleanHistory(t *testing.T) {
 
 	s.cleanHistory()
 
-	if s.HashHistory.Len() != historyLimit {
+	if s.HashHistory.Len() != DefaultHistoryLimit {
 		t.Errorf("cleanHistory failed to clean up hash history")
 	}
 
@@ -188,7 +177,7 @@ func TestHandleEntry(t *testing.T) {
 		block.Hash = common.Hash{byte(i)}
 		block.ParentHash = common.Hash{byte(i - 1)}
 
-		s.handleEntry(block, errCh)
+		s.HandleEntry(block, errCh)
 	}
 
 	if s.Delta.Cmp(big.NewInt(2)) != 0 || s.Issuance.Reward.Cmp(big.NewInt(2)) != 0 {
@@ -213,9 +202,9 @@ func TestRewindTo(t *testing.T) {
 		2: {2},
 		3: {3},
 	}
-	s.HashHistory = orderedmap.New[uint64, map[common.Hash]supplyInfo](historyLimit)
+	s.HashHistory = orderedmap.New[uint64, map[common.Hash]supply.Info](DefaultHistoryLimit)
 
-	blocks := map[uint64]supplyInfo{}
+	blocks := map[uint64]supply.Info{}
 	for i := uint64(0); i < 4; i++ {
 		block := newSupplyInfo()
 		block.Number = i
@@ -225,7 +214,7 @@ func TestRewindTo(t *testing.T) {
 
 		blocks[i] = block
 
-		s.HashHistory.Set(i, map[common.Hash]supplyInfo{block.Hash: block})
+		s.HashHistory.Set(i, map[common.Hash]supply.Info{block.Hash: block})
 	}
 
 	errCh := make(chan error, 1)
@@ -266,9 +255,9 @@ func TestRewindToSameNumber(t *testing.T) {
 		2: {2},
 		3: {3},
 	}
-	s.HashHistory = orderedmap.New[uint64, map[common.Hash]supplyInfo](historyLimit)
+	s.HashHistory = orderedmap.New[uint64, map[common.Hash]supply.Info](DefaultHistoryLimit)
 
-	blocks := map[uint64]supplyInfo{}
+	blocks := map[uint64]supply.Info{}
 	for i := uint64(0); i < 4; i++ {
 		block := newSupplyInfo()
 		block.Number = i
@@ -278,7 +267,7 @@ func TestRewindToSameNumber(t *testing.T) {
 
 		blocks[i] = block
 
-		s.HashHistory.Set(i, map[common.Hash]supplyInfo{block.Hash: block})
+		s.HashHistory.Set(i, map[common.Hash]supply.Info{block.Hash: block})
 	}
 
 	// Add a new block with number 3, but different hash
@@ -329,13 +318,13 @@ func TestForwardTo(t *testing.T) {
 		0: {0},
 		1: {1},
 	}
-	s.HashHistory = orderedmap.New[uint64, map[common.Hash]supplyInfo](historyLimit)
+	s.HashHistory = orderedmap.New[uint64, map[common.Hash]supply.Info](DefaultHistoryLimit)
 
 	big2 := big.NewInt(2)
 
-	blocks := map[uint64]supplyInfo{}
+	blocks := map[uint64]supply.Info{}
 	for i := uint64(0); i < 4; i++ {
-		h := map[common.Hash]supplyInfo{}
+		h := map[common.Hash]supply.Info{}
 
 		blockA := newSupplyInfo()
 		blockA.Number = i
@@ -397,7 +386,7 @@ func TestBlockValidations(t *testing.T) {
 
 	errCh := make(chan error, 16)
 
-	blocks := map[uint64]supplyInfo{}
+	blocks := map[uint64]supply.Info{}
 	for i := uint64(0); i < 3; i++ {
 		block := newSupplyInfo()
 		block.Number = i
@@ -409,7 +398,7 @@ func TestBlockValidations(t *testing.T) {
 	}
 
 	for _, block := range blocks {
-		s.handleEntry(block, errCh)
+		s.HandleEntry(block, errCh)
 	}
 
 	blockWithWrongParent := newSupplyInfo()
@@ -417,7 +406,7 @@ func TestBlockValidations(t *testing.T) {
 	blockWithWrongParent.Issuance.Reward = big1
 	blockWithWrongParent.Hash = common.Hash{3}
 	blockWithWrongParent.ParentHash = common.Hash{1}
-	s.handleEntry(blockWithWrongParent, errCh)
+	s.HandleEntry(blockWithWrongParent, errCh)
 
 	err := <-errCh
 	if !strings.HasPrefix(err.Error(), "skipping block 3 entry") {
@@ -430,10 +419,86 @@ func TestBlockValidations(t *testing.T) {
 	blockWithCorrectParent.Issuance.Reward = big1
 	blockWithCorrectParent.Hash = common.Hash{4}
 	blockWithCorrectParent.ParentHash = common.Hash{2}
-	s.handleEntry(blockWithCorrectParent, errCh)
+	s.HandleEntry(blockWithCorrectParent, errCh)
 
 	if s.BlockNumber != 3 || s.Hash.Cmp(common.Hash{4}) != 0 || s.ParentHash.Cmp(common.Hash{2}) != 0 {
 		err := <-errCh
 		t.Errorf("HandleEntry failed to import next block, while it's correct: %v", err)
 	}
 }
+
+func TestAddSubUnknownCategories(t *testing.T) {
+	s := NewState()
+
+	entry := newSupplyInfo()
+	entry.Issuance.Other = map[string]*big.Int{"newIssuance": big.NewInt(3)}
+	entry.Burn.Other = map[string]*big.Int{"systemContract": big.NewInt(1)}
+
+	s.add(&entry)
+	s.add(&entry)
+	if s.Issuance.Other["newIssuance"].Int64() != 6 || s.Burn.Other["systemContract"].Int64() != 2 || s.Delta.Int64() != 4 {
+		t.Errorf("unexpected totals after add: %v %v %s", s.Issuance.Other, s.Burn.Other, s.Delta)
+	}
+
+	s.sub(&entry)
+	if s.Issuance.Other["newIssuance"].Int64() != 3 || s.Burn.Other["systemContract"].Int64() != 1 || s.Delta.Int64() != 2 {
+		t.Errorf("unexpected totals after sub: %v %v %s", s.Issuance.Other, s.Burn.Other, s.Delta)
+	}
+}
+
+// benchEntry returns a block with all the components and a timestamp
+func benchEntry(number uint64) supply.Info {
+	entry := newSupplyInfo()
+	entry.Number = number
+	entry.Hash = common.Hash{byte(number), byte(number >> 8), byte(number >> 16)}
+	entry.ParentHash = common.Hash{byte(number - 1), byte((number - 1) >> 8), byte((number - 1) >> 16)}
+	entry.Timestamp = 1_700_000_000 + 12*number
+	entry.Issuance.Reward = big.NewInt(2_000_000_000_000_000_000)
+	entry.Issuance.Withdrawals = big.NewInt(9_000_000_000_000_000_000)
+	entry.Burn.EIP1559 = big.NewInt(1_500_000_000_000_000_000)
+	entry.Burn.Blob = big.NewInt(1_000_000_000)
+	entry.Delta = entry.CalculatedDelta()
+
+	return entry
+}
+
+// BenchmarkAddSub measures the accumulation of a block to the totals, eras and series,
+// and its removal on a reorg.
+func BenchmarkAddSub(b *testing.B) {
+	s := NewState()
+	s.SetEras(NetworkEras["mainnet"])
+	entry := benchEntry(20_000_000)
+
+	// Keep the buckets of the block, as when it is reorged
+	parent := benchEntry(20_000_000 - 1)
+	parent.Timestamp = entry.Timestamp
+	s.add(&parent)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.add(&entry)
+		s.sub(&entry)
+	}
+}
+
+// BenchmarkHandleEntry measures the handling of a new block. The accumulation does not
+// allocate, and the maps of the blocks cleaned from history are reused, while adding
+// the number of the block to the ordered HashHistory allocates its pair and list element.
+func BenchmarkHandleEntry(b *testing.B) {
+	s := NewState()
+	s.SetEras(NetworkEras["mainnet"])
+	entries := make([]supply.Info, b.N)
+	for i := range entries {
+		entries[i] = benchEntry(uint64(i) + 1)
+	}
+	errCh := make(chan error, 1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range entries {
+		s.HandleEntry(entries[i], errCh)
+	}
+	if len(errCh) > 0 {
+		b.Fatal(<-errCh)
+	}
+}
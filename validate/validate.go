@@ -0,0 +1,199 @@
+// Package validate checks the supply data for invariant violations.
+package validate
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ziogaschr/supply-tracer-parser/reader"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+// Severity is the severity of a violation
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Rules checked by the validator
+const (
+	RuleDeltaMismatch     = "delta_mismatch"      // Reported delta differs from the calculated one
+	RuleNegativeIssuance  = "negative_issuance"   // Issuance component is negative
+	RuleNegativeBurn      = "negative_burn"       // Burn component is negative
+	RuleNonMonotonicBlock = "non_monotonic_block" // Block number is not greater than the previous one of the file
+	RuleUnknownCategory   = "unknown_category"    // Issuance or burn category unknown to this version, reported once
+)
+
+// recentLimit is the maximum number of recent violations to keep
+const recentLimit = 100
+
+// violationsMetric counts the violations by rule and severity
+var violationsMetric = expvar.NewMap("supply_validation_violations")
+
+// Violation is a failed check of a supply entry
+type Violation struct {
+	Severity Severity        `json:"severity"`
+	Rule     string          `json:"rule"`
+	Number   uint64          `json:"blockNumber"`
+	Hash     common.Hash     `json:"hash"`
+	Pos      reader.Position `json:"position"`
+	Message  string          `json:"message"`
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("%s: %s violation at block %d (%s): %s", v.Severity, v.Rule, v.Number, v.Hash, v.Message)
+}
+
+// Report is a summary of the violations found
+type Report struct {
+	Counts map[string]map[Severity]uint64 `json:"counts"` // Counts by rule and severity
+	Recent []Violation                    `json:"recent"` // Most recent violations, newest last
+}
+
+// Validator checks supply entries for invariant violations
+type Validator struct {
+	mu sync.Mutex
+
+	lastFile   string
+	lastNumber uint64
+
+	unknownCategories map[string]bool // Unknown categories seen, by kind and name
+
+	counts map[string]map[Severity]uint64
+	recent []Violation
+}
+
+// New returns a validator
+func New() *Validator {
+	return &Validator{
+		counts:            make(map[string]map[Severity]uint64),
+		unknownCategories: make(map[string]bool),
+	}
+}
+
+// Check validates a supply entry read at pos, and records, logs and returns the violations.
+// Entries should be checked in the order they are read.
+func (v *Validator) Check(entry supply.Info, pos reader.Position) []Violation {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var violations []Violation
+	violate := func(severity Severity, rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{
+			Severity: severity,
+			Rule:     rule,
+			Number:   entry.Number,
+			Hash:     entry.Hash,
+			Pos:      pos,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	calculated := entry.CalculatedDelta()
+	if entry.ReportedDelta != nil && entry.ReportedDelta.Cmp(calculated) != 0 {
+		violate(SeverityError, RuleDeltaMismatch, "reported delta %s, calculated %s", entry.ReportedDelta, calculated)
+	}
+
+	for _, c := range components(&entry) {
+		if c.value != nil && c.value.Sign() < 0 {
+			violate(SeverityError, c.rule, "%s is %s", c.name, c.value)
+		}
+		if !c.known && !v.unknownCategories[c.name] {
+			v.unknownCategories[c.name] = true
+			violate(SeverityWarning, RuleUnknownCategory, "%s is not known, it is summed generically", c.name)
+		}
+	}
+
+	// Lower or repeated numbers are written on reorgs, so they are not errors
+	if pos.File == v.lastFile && entry.Number <= v.lastNumber {
+		violate(SeverityWarning, RuleNonMonotonicBlock, "block %d follows block %d", entry.Number, v.lastNumber)
+	}
+	v.lastFile = pos.File
+	v.lastNumber = entry.Number
+
+	for _, violation := range violations {
+		v.record(violation)
+	}
+
+	return violations
+}
+
+type component struct {
+	rule  string
+	name  string
+	value *big.Int
+	known bool
+}
+
+// components returns the issuance and burn components of an entry
+func components(entry *supply.Info) []component {
+	var cs []component
+	for _, c := range entry.Issuance.Categories() {
+		cs = append(cs, component{RuleNegativeIssuance, "issuance " + c.Name, c.Amount, c.Known})
+	}
+	for _, c := range entry.Burn.Categories() {
+		cs = append(cs, component{RuleNegativeBurn, "burn " + c.Name, c.Amount, c.Known})
+	}
+
+	return cs
+}
+
+// record counts, logs and keeps a violation
+func (v *Validator) record(violation Violation) {
+	if v.counts[violation.Rule] == nil {
+		v.counts[violation.Rule] = make(map[Severity]uint64)
+	}
+	v.counts[violation.Rule][violation.Severity]++
+	violationsMetric.Add(violation.Rule+"."+violation.Severity.String(), 1)
+
+	v.recent = append(v.recent, violation)
+	if len(v.recent) > recentLimit {
+		v.recent = v.recent[len(v.recent)-recentLimit:]
+	}
+
+	log.Printf("Validation %s\n\tat %s", violation.Error(), violation.Pos)
+}
+
+// Report returns a summary of the violations found
+func (v *Validator) Report() Report {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	report := Report{
+		Counts: make(map[string]map[Severity]uint64, len(v.counts)),
+		Recent: append([]Violation{}, v.recent...),
+	}
+	for rule, counts := range v.counts {
+		report.Counts[rule] = make(map[Severity]uint64, len(counts))
+		for severity, count := range counts {
+			report.Counts[rule][severity] = count
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,113 @@
+package validate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ziogaschr/supply-tracer-parser/reader"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+)
+
+func rules(violations []Violation) []string {
+	var rs []string
+	for _, v := range violations {
+		rs = append(rs, v.Rule)
+	}
+	return rs
+}
+
+func TestCheck(t *testing.T) {
+	v := New()
+	pos := reader.Position{File: "supply.jsonl", Line: 1}
+
+	entry := supply.New()
+	entry.Number = 1
+	entry.Issuance.Reward = big.NewInt(2)
+	entry.ReportedDelta = big.NewInt(2)
+	if violations := v.Check(entry, pos); len(violations) != 0 {
+		t.Errorf("unexpected violations: %v", violations)
+	}
+
+	// Mismatching delta and negative components
+	entry = supply.New()
+	entry.Number = 2
+	entry.Issuance.Reward = big.NewInt(-1)
+	entry.Burn.Blob = big.NewInt(-1)
+	entry.ReportedDelta = big.NewInt(1)
+	pos.Line++
+	violations := v.Check(entry, pos)
+	want := []string{RuleDeltaMismatch, RuleNegativeIssuance, RuleNegativeBurn}
+	if have := rules(violations); len(have) != len(want) || have[0] != want[0] || have[1] != want[1] || have[2] != want[2] {
+		t.Errorf("want violations %v, have %v", want, have)
+	}
+	for _, violation := range violations {
+		if violation.Severity != SeverityError || violation.Pos != pos {
+			t.Errorf("unexpected violation: %+v", violation)
+		}
+	}
+
+	// Repeated block number of a reorg in the same file
+	entry = supply.New()
+	entry.Number = 2
+	pos.Line++
+	violations = v.Check(entry, pos)
+	if len(violations) != 1 || violations[0].Rule != RuleNonMonotonicBlock || violations[0].Severity != SeverityWarning {
+		t.Errorf("want non monotonic block warning, have %v", violations)
+	}
+
+	// Block numbers are checked per file
+	entry.Number = 1
+	if violations := v.Check(entry, reader.Position{File: "supply-2.jsonl", Line: 1}); len(violations) != 0 {
+		t.Errorf("unexpected violations for a new file: %v", violations)
+	}
+
+	report := v.Report()
+	if len(report.Recent) != 4 {
+		t.Errorf("want 4 recent violations, have %d", len(report.Recent))
+	}
+	if report.Counts[RuleNegativeBurn][SeverityError] != 1 || report.Counts[RuleNonMonotonicBlock][SeverityWarning] != 1 {
+		t.Errorf("unexpected counts: %v", report.Counts)
+	}
+}
+
+func TestCheckUnknownCategory(t *testing.T) {
+	v := New()
+	pos := reader.Position{File: "supply.jsonl", Line: 1}
+
+	entry := supply.New()
+	entry.Number = 1
+	entry.Burn.Other = map[string]*big.Int{"systemContract": big.NewInt(1)}
+	violations := v.Check(entry, pos)
+	if len(violations) != 1 || violations[0].Rule != RuleUnknownCategory || violations[0].Severity != SeverityWarning {
+		t.Errorf("want unknown category warning, have %v", violations)
+	}
+
+	// Reported the first time only
+	entry.Number = 2
+	pos.Line++
+	if violations := v.Check(entry, pos); len(violations) != 0 {
+		t.Errorf("unexpected violations: %v", violations)
+	}
+}
+
+func TestCheckNegativeLine(t *testing.T) {
+	line := `{"issuance":{"reward":"-0x1"},"burn":{"eip1559":"0x2","systemContract":"-0x3"},"blockNumber":1,` +
+		`"hash":"0x0000000000000000000000000000000000000000000000000000000000000001",` +
+		`"parentHash":"0x0000000000000000000000000000000000000000000000000000000000000000"}`
+
+	var entry supply.Info
+	if err := entry.UnmarshalJSON([]byte(line)); err != nil {
+		t.Fatalf("negative components fail decoding: %v", err)
+	}
+
+	violations := New().Check(entry, reader.Position{File: "supply.jsonl", Line: 1})
+	want := map[string]bool{RuleNegativeIssuance: true, RuleNegativeBurn: true, RuleUnknownCategory: true}
+	if len(violations) != len(want) {
+		t.Errorf("want violations %v, have %v", want, rules(violations))
+	}
+	for _, violation := range violations {
+		if !want[violation.Rule] {
+			t.Errorf("unexpected violation: %+v", violation)
+		}
+	}
+}
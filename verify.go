@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/urfave/cli/v2"
+	"github.com/ziogaschr/supply-tracer-parser/reader"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+var (
+	verifyJSONFlag = &cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the issues as JSON",
+	}
+
+	verifyCommand = &cli.Command{
+		Name:  "verify",
+		Usage: "Check the chain continuity of the supply files",
+		Description: `Reads the supply file and all its log rotated files once, and reports every gap in the block numbers,
+parent hash discontinuity not explained by a reorg, duplicate record, out of order file and malformed line.
+The parent checks are the ones of the tracker, which continues from the offending record after each issue.
+Exits with a non-zero code when any issue is found.`,
+		Flags: []cli.Flag{
+			supplyFileFlag,
+			verifyJSONFlag,
+		},
+		Action: verify,
+	}
+)
+
+// Kinds of the issues found by verify
+const (
+	issueGap           = "gap"            // Block numbers are missing
+	issueDiscontinuity = "discontinuity"  // The parent of a record is not the head, nor in history to reorg to
+	issueDuplicate     = "duplicate"      // The same block is recorded again
+	issueOutOfOrder    = "out_of_order"   // A file starts before the file sorted before it
+	issueMalformed     = "malformed_line" // A line cannot be decoded
+)
+
+// duplicateWindow is the number of block numbers behind the highest one, whose hashes are kept to find duplicates
+const duplicateWindow = 1024
+
+// verifyIssue is a continuity issue of the supply files
+type verifyIssue struct {
+	Kind    string          `json:"kind"`
+	Pos     reader.Position `json:"pos"`
+	Number  uint64          `json:"blockNumber,omitempty"`
+	Message string          `json:"message"`
+}
+
+func verify(ctx *cli.Context) error {
+	issues, err := verifyFiles(ctx.String(supplyFileFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool(verifyJSONFlag.Name) {
+		out, err := json.MarshalIndent(issues, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal issues: %v", err)
+		}
+		fmt.Fprintln(os.Stdout, string(out))
+	} else {
+		printIssues(os.Stdout, issues)
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("verification found %d issues", len(issues))
+	}
+
+	return nil
+}
+
+// verifier checks the continuity of the blocks, in the order they are read
+type verifier struct {
+	state   *tracker.State
+	started bool
+	highest uint64 // Highest block number read
+
+	seen map[uint64]map[common.Hash]reader.Position // Recent records, by number and hash
+
+	fileStarted   bool   // Whether a block of the current file has been read
+	prevFileFirst uint64 // First block number of the previous file with blocks
+	prevFile      string
+
+	issues []verifyIssue
+}
+
+// verifyFiles returns the issues of the supply file and its log rotated files
+func verifyFiles(path string) ([]verifyIssue, error) {
+	eventsCh, err := reader.ScanFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &verifier{
+		state: tracker.NewState(),
+		seen:  make(map[uint64]map[common.Hash]reader.Position),
+	}
+	for event := range eventsCh {
+		switch event.Kind {
+		case reader.EventFileStart:
+			v.fileStarted = false
+		case reader.EventBlock:
+			v.block(event.Supply, event.Pos)
+		case reader.EventMalformed:
+			v.report(issueMalformed, event.Pos, 0, "%v", event.Err)
+		case reader.EventError:
+			return nil, fmt.Errorf("%v\n\tat %s", event.Err, event.Pos)
+		}
+	}
+
+	return v.issues, nil
+}
+
+func (v *verifier) report(kind string, pos reader.Position, number uint64, format string, args ...interface{}) {
+	v.issues = append(v.issues, verifyIssue{Kind: kind, Pos: pos, Number: number, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *verifier) block(entry supply.Info, pos reader.Position) {
+	if !v.fileStarted {
+		v.fileStarted = true
+		if v.prevFile != "" && entry.Number < v.prevFileFirst {
+			v.report(issueOutOfOrder, pos, entry.Number, "file starts at block %d, before %s which starts at block %d", entry.Number, filepath.Base(v.prevFile), v.prevFileFirst)
+		}
+		v.prevFile, v.prevFileFirst = pos.File, entry.Number
+	}
+
+	// Duplicates are not handled again, so that the state stays at its head
+	if first, ok := v.seen[entry.Number][entry.Hash]; ok {
+		v.report(issueDuplicate, pos, entry.Number, "block %d (%s) is already recorded at %s", entry.Number, entry.Hash, first)
+		return
+	}
+	v.remember(entry, pos)
+
+	if !v.started {
+		v.started = true
+		v.highest = entry.Number
+		v.state.HandleEntry(entry, make(chan error, 16))
+		return
+	}
+
+	if entry.Number > v.highest+1 {
+		v.report(issueGap, pos, entry.Number, "blocks %d to %d are missing", v.highest+1, entry.Number-1)
+		v.restart(entry)
+		return
+	}
+	if entry.Number > v.highest {
+		v.highest = entry.Number
+	}
+
+	errCh := make(chan error, 16)
+	v.state.HandleEntry(entry, errCh)
+	if len(errCh) > 0 {
+		v.report(issueDiscontinuity, pos, entry.Number, "%v", <-errCh)
+		v.restart(entry)
+	}
+}
+
+// restart continues from the entry, with a new state
+func (v *verifier) restart(entry supply.Info) {
+	v.state = tracker.NewState()
+	v.state.HandleEntry(entry, make(chan error, 16))
+	if entry.Number > v.highest {
+		v.highest = entry.Number
+	}
+}
+
+// remember keeps the record to find its duplicates, and forgets the records out of the window
+func (v *verifier) remember(entry supply.Info, pos reader.Position) {
+	hashes := v.seen[entry.Number]
+	if hashes == nil {
+		hashes = make(map[common.Hash]reader.Position)
+		v.seen[entry.Number] = hashes
+	}
+	hashes[entry.Hash] = pos
+
+	if len(v.seen) > 2*duplicateWindow {
+		for number := range v.seen {
+			if number+duplicateWindow < v.highest {
+				delete(v.seen, number)
+			}
+		}
+	}
+}
+
+// printIssues prints the issues, and their number by kind
+func printIssues(out io.Writer, issues []verifyIssue) {
+	counts := make(map[string]int)
+	for _, issue := range issues {
+		counts[issue.Kind]++
+		fmt.Fprintf(out, "%s at %s: %s\n", issue.Kind, issue.Pos, issue.Message)
+	}
+
+	if len(issues) == 0 {
+		fmt.Fprintln(out, "No issues found")
+		return
+	}
+
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	fmt.Fprintf(out, "\n%d issues:\n", len(issues))
+	for _, kind := range kinds {
+		fmt.Fprintf(out, "  %s: %d\n", kind, counts[kind])
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// verifyTestLine returns a supply line of the block number, with one byte hashes
+func verifyTestLine(number uint64, hash, parent byte) string {
+	return fmt.Sprintf(`{"blockNumber":%d,"hash":"%s","parentHash":"%s","issuance":{"reward":"0x2"}}`+"\n", number, common.Hash{hash}.Hex(), common.Hash{parent}.Hex())
+}
+
+func TestVerifyFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		// A reorg of block 2, a malformed line and a duplicate of block 3
+		"supply-2024-01-01T00-00-00.000.jsonl": verifyTestLine(0, 1, 0) + verifyTestLine(1, 2, 1) + verifyTestLine(2, 3, 2) +
+			verifyTestLine(2, 4, 2) + verifyTestLine(3, 5, 4) + "{malformed\n" + verifyTestLine(3, 5, 4),
+		// Blocks 4 and 5 are missing, and block 8 is not a child of block 7
+		"supply-2024-01-02T00-00-00.000.jsonl": verifyTestLine(6, 7, 6) + verifyTestLine(7, 8, 7) + verifyTestLine(8, 9, 99) + verifyTestLine(9, 10, 9),
+		// Starts before the previous file, and cannot be reorged to
+		"supply.jsonl": verifyTestLine(5, 6, 5),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	issues, err := verifyFiles(filepath.Join(dir, "supply.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		kind   string
+		number uint64
+	}{
+		{issueMalformed, 0},
+		{issueDuplicate, 3},
+		{issueGap, 6},
+		{issueDiscontinuity, 8},
+		{issueOutOfOrder, 5},
+		{issueDiscontinuity, 5},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("want %d issues, have %d: %v", len(want), len(issues), issues)
+	}
+	for i, w := range want {
+		if issues[i].Kind != w.kind || issues[i].Number != w.number {
+			t.Errorf("issue %d: want %s of block %d, have %s of block %d (%s)", i, w.kind, w.number, issues[i].Kind, issues[i].Number, issues[i].Message)
+		}
+	}
+	if !strings.Contains(issues[2].Message, "blocks 4 to 5 are missing") {
+		t.Errorf("unexpected gap message %q", issues[2].Message)
+	}
+}
+
+func TestVerifyFilesContinuous(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "supply.jsonl")
+	if err := os.WriteFile(path, []byte(exportTestSupply), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := verifyFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("want no issues, have %v", issues)
+	}
+}
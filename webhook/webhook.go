@@ -0,0 +1,346 @@
+// Package webhook sends HTTP notifications for supply events and anomalies.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+// Event names sent in the payloads
+const (
+	EventBlocks        = "blocks"         // Every configured number of blocks
+	EventReorg         = "reorg"          // Reorg deeper than the configured threshold
+	EventInvalidEntry  = "invalid_entry"  // Entry skipped because of a ParentHash check failure
+	EventNegativeValue = "negative_value" // Entry with a negative issuance or burn component
+	EventStall         = "stall"          // No new blocks for the configured time
+)
+
+const (
+	// SignatureHeader is the header carrying the HMAC-SHA256 signature of the payload,
+	// in the form "sha256=<hex>", when a secret is configured
+	SignatureHeader = "X-Supply-Signature"
+	// EventHeader is the header carrying the event name of the payload
+	EventHeader = "X-Supply-Event"
+
+	queueSize    = 256
+	closeTimeout = 10 * time.Second
+)
+
+// Config is the configuration of the webhook notifications
+type Config struct {
+	URLs   []string // Endpoints to notify
+	Secret string   // Secret to sign the payloads with, no signature when empty
+
+	EveryBlocks  uint64        // Notify every N blocks, 0 disables it
+	ReorgDepth   int           // Notify for reorgs deeper than this
+	StallTimeout time.Duration // Notify when no blocks arrive for this long, 0 disables it
+
+	MaxRetries int           // Retries of a failed delivery
+	Backoff    time.Duration // Delay before the first retry, doubled on every retry
+
+	Client *http.Client
+}
+
+// Payload is the JSON body of a notification
+type Payload struct {
+	Event     string               `json:"event"`
+	Timestamp int64                `json:"timestamp"`
+	Head      tracker.BlockRef     `json:"head"`
+	Message   string               `json:"message,omitempty"`
+	Reorg     *tracker.Reorg       `json:"reorg,omitempty"`
+	Entry     *supply.Info         `json:"entry,omitempty"`
+	Supply    *tracker.TotalSupply `json:"supply,omitempty"`
+}
+
+// Notifier sends webhook notifications for the events of a state
+type Notifier struct {
+	config Config
+
+	queue chan Payload
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	mu       sync.Mutex
+	head     tracker.BlockRef
+	lastHead time.Time
+	stalled  bool
+	closed   bool
+
+	// Last block number of a blocks notification, so that heads replayed after a reorg are not notified again
+	lastEvery    uint64
+	notifiedEver bool
+}
+
+// New returns a notifier for the given configuration
+func New(config Config) *Notifier {
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.Backoff == 0 {
+		config.Backoff = 1 * time.Second
+	}
+
+	return &Notifier{
+		config:   config,
+		queue:    make(chan Payload, queueSize),
+		done:     make(chan struct{}),
+		lastHead: time.Now(),
+	}
+}
+
+// Start starts delivering notifications and watching for stalls
+func (n *Notifier) Start() {
+	n.wg.Add(1)
+	go n.deliverLoop()
+
+	if n.config.StallTimeout > 0 {
+		n.wg.Add(1)
+		go n.watchStall()
+	}
+}
+
+// Close stops watching for stalls and waits for the queued notifications to be delivered,
+// for a limited time
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	n.closed = true
+	close(n.done)
+	close(n.queue)
+	n.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(closeTimeout):
+		log.Println("Timed out delivering the queued webhook notifications")
+	}
+}
+
+// Hooks returns the state hooks triggering the notifications
+func (n *Notifier) Hooks() *tracker.Hooks {
+	return &tracker.Hooks{
+		OnHead:         n.onHead,
+		OnReorg:        n.onReorg,
+		OnInvalidEntry: n.onInvalidEntry,
+	}
+}
+
+func (n *Notifier) onHead(entry supply.Info, total tracker.TotalSupply) {
+	head := tracker.BlockRef{Number: entry.Number, Hash: entry.Hash}
+
+	every := n.config.EveryBlocks > 0 && entry.Number%n.config.EveryBlocks == 0
+
+	n.mu.Lock()
+	n.head = head
+	n.lastHead = time.Now()
+	n.stalled = false
+	if every && n.notifiedEver && entry.Number <= n.lastEvery {
+		every = false
+	}
+	if every {
+		n.lastEvery = entry.Number
+		n.notifiedEver = true
+	}
+	n.mu.Unlock()
+
+	if hasNegativeValue(&entry) {
+		n.send(Payload{
+			Event:   EventNegativeValue,
+			Head:    head,
+			Message: fmt.Sprintf("block %d has a negative issuance or burn value", entry.Number),
+			Entry:   &entry,
+		})
+	}
+
+	if every {
+		n.send(Payload{
+			Event:  EventBlocks,
+			Head:   head,
+			Supply: &total,
+		})
+	}
+}
+
+func (n *Notifier) onReorg(reorg tracker.Reorg, total tracker.TotalSupply) {
+	if reorg.Depth <= n.config.ReorgDepth {
+		return
+	}
+
+	n.send(Payload{
+		Event:   EventReorg,
+		Head:    reorg.NewHead,
+		Message: fmt.Sprintf("reorg of depth %d", reorg.Depth),
+		Reorg:   &reorg,
+		Supply:  &total,
+	})
+}
+
+func (n *Notifier) onInvalidEntry(entry supply.Info, err error) {
+	n.mu.Lock()
+	head := n.head
+	n.mu.Unlock()
+
+	n.send(Payload{
+		Event:   EventInvalidEntry,
+		Head:    head,
+		Message: err.Error(),
+		Entry:   &entry,
+	})
+}
+
+// watchStall notifies once when no new head has been seen for the stall timeout,
+// and again after new heads have arrived in between
+func (n *Notifier) watchStall() {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.config.StallTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-ticker.C:
+			n.mu.Lock()
+			since := time.Since(n.lastHead)
+			stalled := !n.stalled && since >= n.config.StallTimeout
+			if stalled {
+				n.stalled = true
+			}
+			head := n.head
+			n.mu.Unlock()
+
+			if stalled {
+				n.send(Payload{
+					Event:   EventStall,
+					Head:    head,
+					Message: fmt.Sprintf("no new blocks for %s", since.Round(time.Second)),
+				})
+			}
+		}
+	}
+}
+
+// send queues a notification. It doesn't block, notifications are dropped when the queue is full.
+func (n *Notifier) send(payload Payload) {
+	payload.Timestamp = time.Now().Unix()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return
+	}
+
+	select {
+	case n.queue <- payload:
+	default:
+		log.Printf("Webhook queue is full, dropping %q notification", payload.Event)
+	}
+}
+
+func (n *Notifier) deliverLoop() {
+	defer n.wg.Done()
+
+	for payload := range n.queue {
+		body, err := json.Marshal(&payload)
+		if err != nil {
+			log.Printf("Failed to marshal webhook payload: %v", err)
+			continue
+		}
+
+		for _, url := range n.config.URLs {
+			if err := n.deliver(url, payload.Event, body); err != nil {
+				log.Printf("Failed to deliver %q webhook to %s: %v", payload.Event, url, err)
+			}
+		}
+	}
+}
+
+// deliver posts the body to url, retrying with exponential backoff
+func (n *Notifier) deliver(url, event string, body []byte) error {
+	backoff := n.config.Backoff
+
+	var err error
+	for attempt := 0; attempt <= n.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var retry bool
+		retry, err = n.post(url, event, body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+
+	return err
+}
+
+// post posts the body once, and reports if a failure can be retried
+func (n *Notifier) post(url, event string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	if n.config.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(n.config.Secret, body))
+	}
+
+	resp, err := n.config.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status: %s", resp.Status)
+	default:
+		return false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+}
+
+// Sign returns the signature of body for the SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// hasNegativeValue checks if any issuance or burn component of the entry is negative
+func hasNegativeValue(entry *supply.Info) bool {
+	for _, c := range append(entry.Issuance.Categories(), entry.Burn.Categories()...) {
+		if c.Amount != nil && c.Amount.Sign() < 0 {
+			return true
+		}
+	}
+
+	return false
+}
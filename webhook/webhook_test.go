@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ziogaschr/supply-tracer-parser/supply"
+	"github.com/ziogaschr/supply-tracer-parser/tracker"
+)
+
+// receiver is a local stand-in for a webhook endpoint
+type receiver struct {
+	sync.Mutex
+	events    []string
+	failFirst int // Number of requests to fail with a server error
+	requests  int
+	secret    string
+	t         *testing.T
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.requests++
+	if r.requests <= r.failFirst {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := io.ReadAll(req.Body)
+	if r.secret != "" && req.Header.Get(SignatureHeader) != Sign(r.secret, body) {
+		r.t.Errorf("invalid signature %q", req.Header.Get(SignatureHeader))
+	}
+
+	var payload struct {
+		Event string `json:"event"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		r.t.Errorf("invalid payload: %v", err)
+	}
+	if req.Header.Get(EventHeader) != payload.Event {
+		r.t.Errorf("event header %q does not match payload event %q", req.Header.Get(EventHeader), payload.Event)
+	}
+	r.events = append(r.events, payload.Event)
+}
+
+func (r *receiver) receivedEvents() []string {
+	r.Lock()
+	defer r.Unlock()
+
+	return append([]string{}, r.events...)
+}
+
+func TestNotifier(t *testing.T) {
+	rcv := &receiver{secret: "secret", failFirst: 2, t: t}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	n := New(Config{
+		URLs:        []string{server.URL},
+		Secret:      "secret",
+		EveryBlocks: 2,
+		ReorgDepth:  1,
+		MaxRetries:  3,
+		Backoff:     time.Millisecond,
+	})
+	n.Start()
+
+	hooks := n.Hooks()
+	for i := uint64(1); i <= 3; i++ {
+		entry := supply.New()
+		entry.Number = i
+		entry.Hash = common.Hash{byte(i)}
+		if i == 3 {
+			entry.Burn.Misc = big.NewInt(-1)
+		}
+		hooks.OnHead(entry, tracker.TotalSupply{Delta: big.NewInt(0)})
+	}
+
+	// Only the deep reorg is notified
+	hooks.OnReorg(tracker.Reorg{Depth: 1}, tracker.TotalSupply{Delta: big.NewInt(0)})
+	hooks.OnReorg(tracker.Reorg{Depth: 2}, tracker.TotalSupply{Delta: big.NewInt(0)})
+
+	hooks.OnInvalidEntry(supply.New(), errors.New("skipping block"))
+
+	n.Close()
+
+	want := []string{EventBlocks, EventNegativeValue, EventReorg, EventInvalidEntry}
+	have := rcv.receivedEvents()
+	if len(have) != len(want) {
+		t.Fatalf("want events %v, have %v", want, have)
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Errorf("want events %v, have %v", want, have)
+			break
+		}
+	}
+
+	// The first delivery succeeded after retrying
+	if rcv.requests != len(want)+2 {
+		t.Errorf("want %d requests, have %d", len(want)+2, rcv.requests)
+	}
+}
+
+func TestNotifierNoRetryOnClientError(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	n := New(Config{URLs: []string{server.URL}, MaxRetries: 3, Backoff: time.Millisecond})
+	if err := n.deliver(server.URL, EventBlocks, []byte("{}")); err == nil {
+		t.Error("expected delivery error")
+	}
+	if requests != 1 {
+		t.Errorf("want 1 request, have %d", requests)
+	}
+}
+
+func TestNotifierStall(t *testing.T) {
+	rcv := &receiver{t: t}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	n := New(Config{
+		URLs:         []string{server.URL},
+		StallTimeout: 20 * time.Millisecond,
+	})
+	n.Start()
+
+	// A single notification per stall
+	time.Sleep(100 * time.Millisecond)
+	n.Close()
+
+	events := rcv.receivedEvents()
+	if len(events) != 1 || events[0] != EventStall {
+		t.Errorf("want a single stall event, have %v", events)
+	}
+}
+
+func TestNotifierTraceLines(t *testing.T) {
+	rcv := &receiver{t: t}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	n := New(Config{URLs: []string{server.URL}, EveryBlocks: 2, ReorgDepth: 10, Backoff: time.Millisecond})
+	n.Start()
+
+	state := tracker.NewState()
+	state.Subscribe(n.Hooks())
+
+	// Block 2 is reorged out by 2b, which is not notified again,
+	// and the negative burn of 3b is decoded from the trace line
+	hash := func(b byte) string { return common.Hash{b}.Hex() }
+	lines := []string{
+		`{"issuance":{"reward":"0x2"},"blockNumber":0,"hash":"` + hash(0x10) + `","parentHash":"` + hash(0) + `"}`,
+		`{"issuance":{"reward":"0x2"},"blockNumber":1,"hash":"` + hash(0x11) + `","parentHash":"` + hash(0x10) + `"}`,
+		`{"issuance":{"reward":"0x2"},"blockNumber":2,"hash":"` + hash(0x12) + `","parentHash":"` + hash(0x11) + `"}`,
+		`{"issuance":{"reward":"0x2"},"blockNumber":2,"hash":"` + hash(0x22) + `","parentHash":"` + hash(0x11) + `"}`,
+		`{"burn":{"misc":"-0x1"},"blockNumber":3,"hash":"` + hash(0x23) + `","parentHash":"` + hash(0x22) + `"}`,
+		`{"issuance":{"reward":"0x2"},"blockNumber":4,"hash":"` + hash(0x24) + `","parentHash":"` + hash(0x23) + `"}`,
+	}
+	errCh := make(chan error, 16)
+	for _, line := range lines {
+		var entry supply.Info
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid line %s: %v", line, err)
+		}
+		state.HandleEntry(entry, errCh)
+	}
+	if len(errCh) > 0 {
+		t.Fatal(<-errCh)
+	}
+
+	n.Close()
+
+	want := []string{EventBlocks, EventBlocks, EventNegativeValue, EventBlocks}
+	have := rcv.receivedEvents()
+	if len(have) != len(want) {
+		t.Fatalf("want events %v, have %v", want, have)
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Errorf("want events %v, have %v", want, have)
+			break
+		}
+	}
+}